@@ -0,0 +1,54 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	// ErrNotExist is returned by a Backend when the requested object does not exist.
+	ErrNotExist = errors.New("object does not exist")
+	// ErrPreconditionFailed is returned by a Backend when an object exists when it shouldn't, or doesn't match the
+	// conditions of a request.
+	ErrPreconditionFailed = errors.New("precondition failed")
+)
+
+// ObjectAttrs describes an object held within a Backend.
+type ObjectAttrs struct {
+	// Metadata holds the user-provided key/value pairs stored against the object.
+	Metadata map[string]string
+	// CacheControl is the cache control directive to store with the object, where the Backend supports one.
+	CacheControl string
+	// Generation identifies the content of the object, and changes every time the object is created.
+	Generation int64
+	// Metageneration identifies the version of the metadata for a generation of the object, and changes every time the
+	// metadata is updated.
+	Metageneration int64
+	// Created is when this generation of the object was created, according to the Backend's clock, if it's known.
+	Created time.Time
+	// Updated is when the metadata was last updated, according to the Backend's clock, if it's known.
+	Updated time.Time
+}
+
+// Conditions constrain an operation to a particular version of an object. Zero values are ignored.
+type Conditions struct {
+	GenerationMatch     int64
+	MetagenerationMatch int64
+}
+
+// Backend provides the storage primitives which the locking algorithm is built upon. Implementations must return
+// errors which match ErrNotExist and ErrPreconditionFailed, via errors.Is, for a missing object and an unmet condition.
+type Backend interface {
+	// Create creates the object with the metadata and cache control from attrs, but only if it doesn't already exist.
+	Create(ctx context.Context, path string, attrs ObjectAttrs) (*ObjectAttrs, error)
+	// Update sets the metadata of the object, as long as it matches the conditions. Whether keys missing from the
+	// metadata are kept depends on the backend, as GCS merges them, so any key to be cleared must be set to "".
+	Update(ctx context.Context, path string, conditions Conditions, metadata map[string]string) (*ObjectAttrs, error)
+	// Delete deletes the object, as long as it matches the conditions.
+	Delete(ctx context.Context, path string, conditions Conditions) error
+	// Attrs reads the current attributes of the object.
+	Attrs(ctx context.Context, path string) (*ObjectAttrs, error)
+	// List returns the paths of the objects which start with the prefix, in lexicographical order.
+	List(ctx context.Context, prefix string) ([]string, error)
+}
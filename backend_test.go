@@ -0,0 +1,147 @@
+package lock
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var _ Backend = &memoryBackend{}
+
+// memoryBackend is an in-process Backend, allowing the lock algorithm to be tested without a mock server.
+type memoryBackend struct {
+	mutex      sync.Mutex
+	objects    map[string]*ObjectAttrs
+	generation int64
+}
+
+func newMemoryBackend() *memoryBackend {
+	return &memoryBackend{objects: map[string]*ObjectAttrs{}}
+}
+
+func (m *memoryBackend) Create(_ context.Context, path string, attrs ObjectAttrs) (*ObjectAttrs, error) { // nolint:gocritic
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if _, ok := m.objects[path]; ok {
+		return nil, fmt.Errorf("%w: %s already exists", ErrPreconditionFailed, path)
+	}
+
+	m.generation++
+	now := time.Now().UTC()
+	m.objects[path] = &ObjectAttrs{
+		Metadata:       maps.Clone(attrs.Metadata),
+		CacheControl:   attrs.CacheControl,
+		Generation:     m.generation,
+		Metageneration: 1,
+		Created:        now,
+		Updated:        now,
+	}
+
+	return m.copy(path), nil
+}
+
+func (m *memoryBackend) Update(_ context.Context, path string, conditions Conditions, metadata map[string]string) (*ObjectAttrs, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if err := m.check(path, conditions); err != nil {
+		return nil, err
+	}
+
+	m.objects[path].Metadata = maps.Clone(metadata)
+	m.objects[path].Metageneration++
+	m.objects[path].Updated = time.Now().UTC()
+
+	return m.copy(path), nil
+}
+
+func (m *memoryBackend) Delete(_ context.Context, path string, conditions Conditions) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if err := m.check(path, conditions); err != nil {
+		return err
+	}
+
+	delete(m.objects, path)
+	return nil
+}
+
+func (m *memoryBackend) Attrs(_ context.Context, path string) (*ObjectAttrs, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if _, ok := m.objects[path]; !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotExist, path)
+	}
+
+	return m.copy(path), nil
+}
+
+func (m *memoryBackend) List(_ context.Context, prefix string) ([]string, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	var paths []string
+	for path := range m.objects {
+		if strings.HasPrefix(path, prefix) {
+			paths = append(paths, path)
+		}
+	}
+	slices.Sort(paths)
+
+	return paths, nil
+}
+
+func (m *memoryBackend) check(path string, conditions Conditions) error {
+	o, ok := m.objects[path]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrNotExist, path)
+	}
+
+	return checkConditions(o, conditions)
+}
+
+func (m *memoryBackend) copy(path string) *ObjectAttrs {
+	o := *m.objects[path]
+	o.Metadata = maps.Clone(o.Metadata)
+	return &o
+}
+
+func TestLock_WithMemoryBackend(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	t.Cleanup(cancel)
+
+	backend := newMemoryBackend()
+	logger := func(context.Context) Logger {
+		return loggerToTestingT{t}
+	}
+
+	first := NewLockWithBackend(backend, "first", "testing", time.Minute, logger)
+	second := NewLockWithBackend(backend, "second", "testing", time.Minute, logger)
+
+	require.NoError(t, first.Lock(ctx, 100*time.Millisecond))
+	assert.ErrorIs(t, second.Lock(ctx, 100*time.Millisecond), context.DeadlineExceeded)
+
+	require.NoError(t, first.RefreshLock(ctx))
+	assert.Equal(t, int64(2), backend.objects["testing"].Metageneration)
+
+	require.NoError(t, first.Unlock(ctx))
+	require.NoError(t, second.Lock(ctx, 100*time.Millisecond))
+	assert.Greater(t, second.FencingToken(), int64(1))
+
+	// The old holder can't refresh or remove the lock now that someone else holds it
+	first.refreshMetadata = true
+	assert.ErrorIs(t, first.RefreshLock(ctx), ErrLockAbandoned)
+	assert.ErrorIs(t, first.Unlock(ctx), ErrNotOwner)
+	assert.Equal(t, "second", backend.objects["testing"].Metadata[ownerMetadata])
+}
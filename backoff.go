@@ -0,0 +1,141 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"time"
+)
+
+// Backoff decides how long Lock waits between attempts to acquire the lock, so that many clients contending for the
+// same lock don't exceed the rate limits of the Backend.
+type Backoff interface {
+	// Delay returns how long to wait after the given failed attempt, counting from 1, where previous is the delay
+	// returned for the attempt before it, or zero for the first attempt.
+	Delay(attempt int, previous time.Duration) time.Duration
+}
+
+// ConstantBackoff waits the same interval between every attempt.
+func ConstantBackoff(interval time.Duration) Backoff {
+	return constantBackoff{interval: interval}
+}
+
+// ExponentialBackoff doubles the wait after every attempt, starting from base and never exceeding maximum.
+func ExponentialBackoff(base, maximum time.Duration) Backoff {
+	return exponentialBackoff{base: base, maximum: maximum}
+}
+
+// DecorrelatedJitterBackoff waits a random time between base and three times the previous wait, never exceeding
+// maximum. This spreads out clients which started contending at the same time, while still backing off as contention
+// continues.
+func DecorrelatedJitterBackoff(base, maximum time.Duration) Backoff {
+	return decorrelatedJitterBackoff{base: base, maximum: maximum}
+}
+
+type constantBackoff struct {
+	interval time.Duration
+}
+
+func (c constantBackoff) Delay(int, time.Duration) time.Duration {
+	return c.interval
+}
+
+func (c constantBackoff) validate() error {
+	if c.interval <= 0 {
+		return errors.New("retry interval must be positive")
+	}
+
+	return nil
+}
+
+type exponentialBackoff struct {
+	base    time.Duration
+	maximum time.Duration
+}
+
+func (e exponentialBackoff) Delay(attempt int, _ time.Duration) time.Duration {
+	delay := e.base
+	for i := 1; i < attempt && delay < e.maximum; i++ {
+		delay *= 2
+	}
+
+	return min(delay, e.maximum)
+}
+
+func (e exponentialBackoff) validate() error {
+	return validateBackoffRange(e.base, e.maximum)
+}
+
+type decorrelatedJitterBackoff struct {
+	base    time.Duration
+	maximum time.Duration
+}
+
+func (d decorrelatedJitterBackoff) Delay(_ int, previous time.Duration) time.Duration {
+	upper := max(previous*3, d.base)
+	delay := d.base + rand.N(upper-d.base+1) // nolint:gosec
+	return min(delay, d.maximum)
+}
+
+func (d decorrelatedJitterBackoff) validate() error {
+	return validateBackoffRange(d.base, d.maximum)
+}
+
+// permanentError is returned by an attempt passed to retry to stop retrying straight away, as another attempt won't
+// help. retry returns the error it wraps.
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+// retry calls attempt until it succeeds or the context times out, waiting between attempts as decided by the Backoff.
+// If the context times out, a TimeoutError wrapping the error from the last attempt is returned. An attempt cut short by
+// the timeout is skipped over, as its error only says that it timed out rather than why the attempts were failing. An
+// attempt returning a permanentError stops the retries.
+func retry(ctx context.Context, clock Clock, timeout time.Duration, backoff Backoff, attempt func(context.Context) error) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var last error
+	var delay time.Duration
+	for attempts := 1; ; attempts++ {
+		select {
+		case <-ctx.Done():
+			return &TimeoutError{Attempts: attempts - 1, Last: last, ctxErr: ctx.Err()}
+		default:
+			err := attempt(ctx)
+			if err == nil {
+				return nil
+			}
+			var permanent *permanentError
+			if errors.As(err, &permanent) {
+				return permanent.err
+			}
+			if ctx.Err() == nil || last == nil {
+				last = err
+			}
+		}
+
+		delay = backoff.Delay(attempts, delay)
+		wait(ctx, clock, delay)
+	}
+}
+
+func validateBackoffRange(base, maximum time.Duration) error {
+	var errs []error
+	if base <= 0 {
+		errs = append(errs, errors.New("backoff base must be positive"))
+	}
+	if maximum < base {
+		errs = append(errs, errors.New("backoff maximum must not be less than the base"))
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,142 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackoff_Delay(t *testing.T) {
+	tests := []struct {
+		name     string
+		backoff  Backoff
+		attempt  int
+		previous time.Duration
+		minimum  time.Duration
+		maximum  time.Duration
+	}{
+		{
+			name:    "constant",
+			backoff: ConstantBackoff(time.Second),
+			attempt: 10,
+			minimum: time.Second,
+			maximum: time.Second,
+		},
+		{
+			name:    "exponential-first-attempt",
+			backoff: ExponentialBackoff(100*time.Millisecond, time.Second),
+			attempt: 1,
+			minimum: 100 * time.Millisecond,
+			maximum: 100 * time.Millisecond,
+		},
+		{
+			name:    "exponential-doubles",
+			backoff: ExponentialBackoff(100*time.Millisecond, time.Second),
+			attempt: 3,
+			minimum: 400 * time.Millisecond,
+			maximum: 400 * time.Millisecond,
+		},
+		{
+			name:    "exponential-capped",
+			backoff: ExponentialBackoff(100*time.Millisecond, time.Second),
+			attempt: 100,
+			minimum: time.Second,
+			maximum: time.Second,
+		},
+		{
+			name:    "decorrelated-jitter-first-attempt",
+			backoff: DecorrelatedJitterBackoff(100*time.Millisecond, time.Second),
+			attempt: 1,
+			minimum: 100 * time.Millisecond,
+			maximum: 100 * time.Millisecond,
+		},
+		{
+			name:     "decorrelated-jitter-grows",
+			backoff:  DecorrelatedJitterBackoff(100*time.Millisecond, 10*time.Second),
+			attempt:  2,
+			previous: time.Second,
+			minimum:  100 * time.Millisecond,
+			maximum:  3 * time.Second,
+		},
+		{
+			name:     "decorrelated-jitter-capped",
+			backoff:  DecorrelatedJitterBackoff(100*time.Millisecond, time.Second),
+			attempt:  2,
+			previous: time.Second,
+			minimum:  100 * time.Millisecond,
+			maximum:  time.Second,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for range 100 {
+				delay := test.backoff.Delay(test.attempt, test.previous)
+				assert.GreaterOrEqual(t, delay, test.minimum)
+				assert.LessOrEqual(t, delay, test.maximum)
+			}
+		})
+	}
+}
+
+func TestLock_Lock_CancelledDuringBackoff(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	t.Cleanup(cancel)
+
+	backend := newMemoryBackend()
+	first, err := New(backend, "first", "path/to/file.lock", WithTTL(time.Minute))
+	require.NoError(t, err)
+	second, err := New(backend, "second", "path/to/file.lock", WithTTL(time.Minute), WithRetryInterval(time.Hour))
+	require.NoError(t, err)
+
+	require.NoError(t, first.Lock(ctx, time.Second))
+
+	started := time.Now()
+	assert.ErrorIs(t, second.Lock(ctx, 100*time.Millisecond), context.DeadlineExceeded)
+	assert.Less(t, time.Since(started), 10*time.Second)
+}
+
+func TestRetry_TimesOutDuringAttempt(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	t.Cleanup(cancel)
+
+	attempts := 0
+	err := retry(ctx, realClock{}, 100*time.Millisecond, ConstantBackoff(time.Millisecond), func(ctx context.Context) error {
+		attempts++
+		if attempts == 1 {
+			return &LockHeldError{Holder: Holder{Owner: "other"}}
+		}
+
+		// Later attempts don't finish until the timeout is reached
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	var timeoutErr *TimeoutError
+	require.ErrorAs(t, err, &timeoutErr)
+	assert.Equal(t, 2, timeoutErr.Attempts)
+	assert.ErrorIs(t, err, ErrLockHeld)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestRetry_StopsOnPermanentError(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	t.Cleanup(cancel)
+
+	failure := errors.New("failure")
+	attempts := 0
+	err := retry(ctx, realClock{}, time.Minute, ConstantBackoff(time.Millisecond), func(context.Context) error {
+		attempts++
+		if attempts == 1 {
+			return &LockHeldError{Holder: Holder{Owner: "other"}}
+		}
+		return &permanentError{err: failure}
+	})
+
+	assert.Equal(t, failure, err)
+	assert.Equal(t, 2, attempts)
+}
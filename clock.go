@@ -0,0 +1,95 @@
+package lock
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Clock tells the time and schedules the waits of a Lock, such as between attempts to acquire it and between refreshes,
+// so that the passing of time can be controlled in tests. Timeouts given as a duration are still enforced by the
+// context, so use real time.
+type Clock interface {
+	Now() time.Time
+	// AfterFunc calls f once the duration has elapsed, unless the returned function is called first to stop it, which
+	// reports whether it was stopped before f was called. f must not block.
+	AfterFunc(d time.Duration, f func()) (stop func() bool)
+}
+
+// realClock is the Clock which uses the system time.
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) AfterFunc(d time.Duration, f func()) func() bool {
+	return time.AfterFunc(d, f).Stop
+}
+
+// wait blocks until the duration has elapsed by the clock or the context is done.
+func wait(ctx context.Context, clock Clock, d time.Duration) {
+	if d <= 0 {
+		return
+	}
+
+	elapsed := make(chan struct{})
+	stop := clock.AfterFunc(d, func() {
+		close(elapsed)
+	})
+	defer stop()
+
+	select {
+	case <-ctx.Done():
+	case <-elapsed:
+	}
+}
+
+// ticker delivers the time by the clock on C every interval, dropping ticks for a slow receiver in the same way as a
+// time.Ticker.
+type ticker struct {
+	C <-chan time.Time
+
+	c        chan time.Time
+	clock    Clock
+	interval time.Duration
+
+	mutex   sync.Mutex
+	stopped bool
+	stop    func() bool
+}
+
+func newTicker(clock Clock, interval time.Duration) *ticker {
+	c := make(chan time.Time, 1)
+	t := &ticker{C: c, c: c, clock: clock, interval: interval}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.stop = clock.AfterFunc(interval, t.tick)
+
+	return t
+}
+
+func (t *ticker) tick() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.stopped {
+		return
+	}
+
+	select {
+	case t.c <- t.clock.Now():
+	default:
+	}
+	t.stop = t.clock.AfterFunc(t.interval, t.tick)
+}
+
+// Stop stops any more ticks from being delivered.
+func (t *ticker) Stop() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.stopped = true
+	t.stop()
+}
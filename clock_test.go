@@ -0,0 +1,65 @@
+package lock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thg-ice/distributed-lock/mock_clock"
+)
+
+func TestWait(t *testing.T) {
+	clock := mock_clock.NewClock(time.Now())
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		wait(context.Background(), clock, time.Minute)
+	}()
+
+	require.Eventually(t, func() bool { return clock.Timers() == 1 }, 5*time.Second, time.Millisecond)
+	clock.Advance(time.Minute - time.Millisecond)
+	select {
+	case <-done:
+		t.Fatal("returned before the duration had elapsed")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	clock.Advance(time.Millisecond)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("didn't return once the duration had elapsed")
+	}
+
+	// The timer is stopped when the context is done first
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	wait(ctx, clock, time.Minute)
+	assert.Zero(t, clock.Timers())
+}
+
+func TestTicker(t *testing.T) {
+	start := time.Now()
+	clock := mock_clock.NewClock(start)
+
+	subject := newTicker(clock, time.Second)
+
+	clock.Advance(500 * time.Millisecond)
+	assert.Empty(t, subject.C)
+
+	clock.Advance(500 * time.Millisecond)
+	assert.Equal(t, start.Add(time.Second), <-subject.C)
+
+	// Ticks are dropped while nothing is receiving them
+	clock.Advance(3 * time.Second)
+	assert.Equal(t, start.Add(2*time.Second), <-subject.C)
+	assert.Empty(t, subject.C)
+
+	subject.Stop()
+	clock.Advance(time.Minute)
+	assert.Empty(t, subject.C)
+	assert.Zero(t, clock.Timers())
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/thg-ice/distributed-lock/mock_gcs"
+)
+
+// bucketName matches the names which Google Cloud Storage allows for buckets, which also keeps them safe to use as file
+// names.
+var bucketName = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]{1,220}[a-z0-9]$`)
+
+// stateExt is the extension of the files the state of each bucket is persisted in.
+const stateExt = ".json"
+
+// emulator serves a mock Google Cloud Storage server for each bucket, optionally persisting their objects to a
+// directory after every change.
+type emulator struct {
+	m       sync.Mutex
+	buckets map[string]*mock_gcs.Server
+	// fixed is set if only the buckets given up front are served, rather than creating them when they're first used
+	fixed bool
+	dir   string
+	mux   *http.ServeMux
+}
+
+// newEmulator creates an emulator serving the buckets, restoring any which have been persisted to the directory. If no
+// buckets are given, they're created when they're first used. If the directory is empty, nothing is persisted.
+func newEmulator(buckets []string, dir string) (*emulator, error) {
+	e := &emulator{
+		buckets: map[string]*mock_gcs.Server{},
+		fixed:   len(buckets) > 0,
+		dir:     dir,
+	}
+
+	for _, bucket := range buckets {
+		if !bucketName.MatchString(bucket) {
+			return nil, fmt.Errorf("invalid bucket name %q", bucket)
+		}
+		e.buckets[bucket] = newBucket(bucket)
+	}
+
+	if dir != "" {
+		if err := e.restore(); err != nil {
+			return nil, err
+		}
+	}
+
+	e.mux = http.NewServeMux()
+	for _, prefix := range []string{"/storage/v1/b/{bucket}/", "/upload/storage/v1/b/{bucket}/", "/b/{bucket}/"} {
+		e.mux.HandleFunc(prefix, e.serveBucket)
+	}
+
+	return e, nil
+}
+
+func newBucket(bucket string) *mock_gcs.Server {
+	return mock_gcs.NewServer(bucket, mock_gcs.WithoutJournal())
+}
+
+// restore loads the buckets persisted to the directory.
+func (e *emulator) restore() error {
+	paths, err := filepath.Glob(filepath.Join(e.dir, "*"+stateExt))
+	if err != nil {
+		return err
+	}
+
+	for _, path := range paths {
+		bucket := strings.TrimSuffix(filepath.Base(path), stateExt)
+		server, ok := e.buckets[bucket]
+		if !ok {
+			if e.fixed {
+				continue
+			}
+			server = newBucket(bucket)
+			e.buckets[bucket] = server
+		}
+
+		if err := load(server, path); err != nil {
+			return fmt.Errorf("unable to restore bucket %s: %w", bucket, err)
+		}
+	}
+
+	return nil
+}
+
+func (e *emulator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	e.mux.ServeHTTP(w, r)
+}
+
+// serveBucket passes the request to the server for the bucket, persisting the bucket if the request may have changed
+// it.
+func (e *emulator) serveBucket(w http.ResponseWriter, r *http.Request) {
+	bucket := r.PathValue("bucket")
+	if !bucketName.MatchString(bucket) {
+		http.Error(w, fmt.Sprintf("invalid bucket name %q", bucket), http.StatusBadRequest)
+		return
+	}
+
+	server := e.bucket(bucket)
+	if server == nil {
+		http.Error(w, fmt.Sprintf("bucket %s not found", bucket), http.StatusNotFound)
+		return
+	}
+
+	server.Handler().ServeHTTP(w, r)
+
+	if e.dir == "" || r.Method == http.MethodGet || r.Method == http.MethodHead {
+		return
+	}
+	if err := e.persist(bucket, server); err != nil {
+		// The response has already been sent, so the most that can be done is to report it
+		fmt.Fprintf(os.Stderr, "Failed to persist bucket %s: %s\n", bucket, err)
+	}
+}
+
+// bucket returns the server for the bucket, creating it if buckets aren't fixed, otherwise nil if it doesn't exist.
+func (e *emulator) bucket(bucket string) *mock_gcs.Server {
+	e.m.Lock()
+	defer e.m.Unlock()
+
+	server, ok := e.buckets[bucket]
+	if !ok && !e.fixed {
+		server = newBucket(bucket)
+		e.buckets[bucket] = server
+	}
+	return server
+}
+
+// persist saves the objects in the bucket to the directory, replacing the file atomically so that a crash part way
+// through doesn't lose it.
+func (e *emulator) persist(bucket string, server *mock_gcs.Server) error {
+	e.m.Lock()
+	defer e.m.Unlock()
+
+	f, err := os.CreateTemp(e.dir, bucket+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = os.Remove(f.Name())
+	}()
+
+	if err := server.Save(f); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), filepath.Join(e.dir, bucket+stateExt))
+}
+
+// Close shuts down the servers for every bucket.
+func (e *emulator) Close() {
+	e.m.Lock()
+	defer e.m.Unlock()
+
+	for _, server := range e.buckets {
+		server.Close()
+	}
+}
+
+func load(server *mock_gcs.Server, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+
+	return errors.Join(server.Load(f), f.Close())
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	lock "github.com/thg-ice/distributed-lock"
+)
+
+func TestEmulator(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	t.Cleanup(cancel)
+
+	dir := t.TempDir()
+	client := startEmulator(ctx, t, nil, dir)
+
+	// Locks can be taken in any bucket, using the emulator as the storage client would use Google Cloud Storage
+	first, err := lock.New(lock.NewGCSBackend(client.Bucket("first")), "id", "testing", lock.WithTTL(time.Minute))
+	require.NoError(t, err)
+	require.NoError(t, first.Lock(ctx, time.Second))
+
+	second, err := lock.New(lock.NewGCSBackend(client.Bucket("second")), "id", "testing", lock.WithTTL(time.Minute))
+	require.NoError(t, err)
+	require.NoError(t, second.Lock(ctx, time.Second))
+	require.NoError(t, second.RefreshLock(ctx))
+	require.NoError(t, second.Unlock(ctx))
+
+	other, err := lock.New(lock.NewGCSBackend(client.Bucket("first")), "other", "testing", lock.WithTTL(time.Minute))
+	require.NoError(t, err)
+	acquired, holder, err := other.TryLock(ctx)
+	require.NoError(t, err)
+	assert.False(t, acquired)
+	assert.Equal(t, "id", holder.Owner)
+
+	// The locks are still held once the emulator is restarted
+	client = startEmulator(ctx, t, nil, dir)
+
+	info, err := lock.Inspect(ctx, lock.NewGCSBackend(client.Bucket("first")), "testing")
+	require.NoError(t, err)
+	require.NotNil(t, info)
+	assert.Equal(t, "id", info.Owner)
+	assert.Equal(t, first.FencingToken(), info.Generation)
+
+	info, err = lock.Inspect(ctx, lock.NewGCSBackend(client.Bucket("second")), "testing")
+	require.NoError(t, err)
+	assert.Nil(t, info)
+
+	// Generations carry on from where they were
+	require.NoError(t, second.Lock(ctx, time.Second))
+	assert.Greater(t, second.FencingToken(), first.FencingToken())
+}
+
+func TestEmulator_FixedBuckets(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	t.Cleanup(cancel)
+
+	client := startEmulator(ctx, t, []string{"known"}, "")
+
+	_, err := client.Bucket("known").Object("object").Attrs(ctx)
+	assert.ErrorIs(t, err, storage.ErrObjectNotExist)
+
+	err = client.Bucket("unknown").Object("object").NewWriter(ctx).Close()
+	assert.ErrorContains(t, err, "bucket unknown not found")
+
+	_, err = newEmulator([]string{"Invalid"}, "")
+	assert.ErrorContains(t, err, `invalid bucket name "Invalid"`)
+}
+
+// startEmulator serves an emulator over plain HTTP, returning a storage client which uses it through
+// STORAGE_EMULATOR_HOST.
+func startEmulator(ctx context.Context, t *testing.T, buckets []string, dir string) *storage.Client {
+	t.Helper()
+
+	e, err := newEmulator(buckets, dir)
+	require.NoError(t, err)
+	t.Cleanup(e.Close)
+
+	server := httptest.NewServer(e)
+	t.Cleanup(server.Close)
+
+	t.Setenv("STORAGE_EMULATOR_HOST", strings.TrimPrefix(server.URL, "http://"))
+	client, err := storage.NewClient(ctx)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+
+	client.SetRetry(storage.WithMaxAttempts(1))
+
+	return client
+}
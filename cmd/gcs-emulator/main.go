@@ -0,0 +1,74 @@
+// Command gcs-emulator serves the mock Google Cloud Storage server over plain HTTP, so that anything able to use a
+// Google Cloud Storage emulator can share locks with it, such as Go clients with STORAGE_EMULATOR_HOST set to the
+// address it listens on.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := run(ctx, os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// run serves the emulator until the context is cancelled.
+func run(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("gcs-emulator", flag.ContinueOnError)
+	addr := flags.String("addr", "localhost:9023", "Address to listen on")
+	buckets := flags.String("buckets", "", "Comma separated buckets to serve, otherwise buckets are created when first used")
+	dir := flags.String("dir", "", "Directory to persist objects to, otherwise they're only held in memory")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	var names []string
+	if *buckets != "" {
+		names = strings.Split(*buckets, ",")
+	}
+	if *dir != "" {
+		if err := os.MkdirAll(*dir, 0o750); err != nil {
+			return fmt.Errorf("unable to create directory: %w", err)
+		}
+	}
+
+	e, err := newEmulator(names, *dir)
+	if err != nil {
+		return err
+	}
+	defer e.Close()
+
+	listener, err := net.Listen("tcp", *addr)
+	if err != nil {
+		return err
+	}
+
+	server := &http.Server{Handler: e, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+
+	fmt.Fprintf(os.Stderr, "Listening on %s, set STORAGE_EMULATOR_HOST=%s to use it\n", listener.Addr(), listener.Addr())
+	if err := server.Serve(listener); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	t.Cleanup(cancel)
+
+	assert.Error(t, run(ctx, []string{"-unknown"}))
+	assert.ErrorContains(t, run(ctx, []string{"-buckets", "a,Invalid"}), "invalid bucket name")
+
+	// Serves until cancelled, creating the directory to persist to
+	dir := filepath.Join(t.TempDir(), "state")
+	runCtx, stop := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer stop()
+	require.NoError(t, run(runCtx, []string{"-addr", "127.0.0.1:0", "-dir", dir}))
+	assert.DirExists(t, dir)
+}
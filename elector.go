@@ -0,0 +1,151 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// LeaderCallbacks are invoked by an Elector as leadership is gained and lost.
+type LeaderCallbacks struct {
+	// OnStartedLeading is called in its own goroutine once leadership has been acquired. The context is cancelled as
+	// soon as leadership is lost or the Elector is stopped, and the function is expected to return promptly after that.
+	OnStartedLeading func(ctx context.Context)
+	// OnStoppedLeading is called once OnStartedLeading has returned and the lock has been released. Optional.
+	OnStoppedLeading func()
+	// OnNewLeader is called whenever the observed leader changes, including when this instance becomes the leader.
+	// Optional.
+	OnNewLeader func(identity string)
+}
+
+// ElectorConfig configures an Elector.
+type ElectorConfig struct {
+	// RetryPeriod is how long a single campaign for leadership lasts before the current leader is observed again.
+	RetryPeriod time.Duration
+	// Callbacks are invoked as leadership changes.
+	Callbacks LeaderCallbacks
+}
+
+// Elector continually campaigns for leadership using a Lock, re-campaigning whenever leadership is lost.
+type Elector struct {
+	lock   *Lock
+	config ElectorConfig
+
+	mutex    sync.Mutex
+	isLeader bool
+	leader   string
+}
+
+// NewElector creates a new Elector which campaigns for leadership using the given lock.
+func NewElector(lock *Lock, config ElectorConfig) (*Elector, error) {
+	if lock == nil {
+		return nil, errors.New("lock must be provided")
+	}
+	if config.RetryPeriod <= 0 {
+		return nil, errors.New("retry period must be positive")
+	}
+	if config.Callbacks.OnStartedLeading == nil {
+		return nil, errors.New("OnStartedLeading callback must be provided")
+	}
+
+	return &Elector{
+		lock:   lock,
+		config: config,
+		mutex:  sync.Mutex{},
+	}, nil
+}
+
+// Run campaigns for leadership until the context is done, running the configured callbacks each time leadership is
+// gained or lost. Leadership is released before Run returns. Run must not be called concurrently.
+func (e *Elector) Run(ctx context.Context) {
+	for ctx.Err() == nil {
+		clock := e.lock.clock
+		started := clock.Now()
+
+		if !e.lead(ctx) {
+			e.observeLeader(ctx)
+			wait(ctx, clock, started.Add(e.config.RetryPeriod).Sub(clock.Now()))
+		}
+	}
+}
+
+// IsLeader reports whether this instance currently holds leadership.
+func (e *Elector) IsLeader() bool {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	return e.isLeader
+}
+
+// CurrentLeader returns the identity of the most recently observed leader, or an empty string if there isn't one.
+func (e *Elector) CurrentLeader() string {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	return e.leader
+}
+
+// lead makes a single campaign for leadership, and if successful, holds on to it until it is lost or the context is
+// done. It returns false if leadership was never acquired.
+func (e *Elector) lead(ctx context.Context) bool {
+	leaderCtx, release, err := e.lock.LockContext(ctx, e.config.RetryPeriod)
+	if err != nil {
+		// Someone else leading is expected, but anything else would otherwise be retried without a trace
+		if ctx.Err() == nil && !errors.Is(err, ErrLockHeld) {
+			e.lock.logger(ctx).Error(err, "Failed to campaign for leadership", "path", e.lock.path)
+		}
+		return false
+	}
+
+	e.setLeader(e.lock.identity, true)
+	e.lock.logger(ctx).Info("Started leading", "path", e.lock.path)
+
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		e.config.Callbacks.OnStartedLeading(leaderCtx)
+	}()
+
+	<-leaderCtx.Done()
+	<-finished
+
+	if err := release(); err != nil {
+		e.lock.logger(ctx).Error(err, "Failed to release leadership", "path", e.lock.path)
+	}
+
+	e.setLeader("", false)
+	e.lock.logger(ctx).Info("Stopped leading", "path", e.lock.path, "reason", context.Cause(leaderCtx).Error())
+
+	if e.config.Callbacks.OnStoppedLeading != nil {
+		e.config.Callbacks.OnStoppedLeading()
+	}
+
+	return true
+}
+
+func (e *Elector) observeLeader(ctx context.Context) {
+	if ctx.Err() != nil {
+		return
+	}
+
+	owner, err := e.lock.owner(ctx)
+	if err != nil {
+		e.lock.logger(ctx).Error(err, "Failed to observe leader", "path", e.lock.path)
+		return
+	}
+
+	e.setLeader(owner, false)
+}
+
+func (e *Elector) setLeader(identity string, isLeader bool) {
+	e.mutex.Lock()
+	changed := e.leader != identity
+	e.leader = identity
+	e.isLeader = isLeader
+	e.mutex.Unlock()
+
+	if changed && identity != "" && e.config.Callbacks.OnNewLeader != nil {
+		e.config.Callbacks.OnNewLeader(identity)
+	}
+}
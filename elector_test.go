@@ -0,0 +1,214 @@
+package lock
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thg-ice/distributed-lock/mock_gcs"
+)
+
+func TestNewElector(t *testing.T) {
+	lock := NewLock(nil, "id", "testing", time.Minute, nil)
+	callbacks := LeaderCallbacks{OnStartedLeading: func(context.Context) {}}
+
+	tests := []struct {
+		name        string
+		lock        *Lock
+		config      ElectorConfig
+		expectedErr string
+	}{
+		{
+			name:   "valid",
+			lock:   lock,
+			config: ElectorConfig{RetryPeriod: time.Second, Callbacks: callbacks},
+		},
+		{
+			name:        "requires-lock",
+			config:      ElectorConfig{RetryPeriod: time.Second, Callbacks: callbacks},
+			expectedErr: "lock must be provided",
+		},
+		{
+			name:        "requires-retry-period",
+			lock:        lock,
+			config:      ElectorConfig{Callbacks: callbacks},
+			expectedErr: "retry period must be positive",
+		},
+		{
+			name:        "requires-started-leading-callback",
+			lock:        lock,
+			config:      ElectorConfig{RetryPeriod: time.Second},
+			expectedErr: "OnStartedLeading callback must be provided",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			elector, err := NewElector(test.lock, test.config)
+			if test.expectedErr == "" {
+				require.NoError(t, err)
+				assert.NotNil(t, elector)
+			} else {
+				assert.EqualError(t, err, test.expectedErr)
+			}
+		})
+	}
+}
+
+func TestElector_Run(t *testing.T) {
+	mock := mock_gcs.NewServer("b", mock_gcs.WithFailOnObjectExistence())
+	t.Cleanup(mock.Close)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	t.Cleanup(cancel)
+
+	client, err := mock.Client(ctx)
+	require.NoError(t, err)
+
+	first, firstStarted, firstStopped := newTestElector(t, client, "first")
+	second, _, _ := newTestElector(t, client, "second")
+
+	firstCtx, stopFirst := context.WithCancel(ctx)
+	secondCtx, stopSecond := context.WithCancel(ctx)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		first.Run(firstCtx)
+	}()
+
+	require.Eventually(t, first.IsLeader, 10*time.Second, 10*time.Millisecond)
+	assert.Equal(t, "first", first.CurrentLeader())
+
+	// Losing the lock causes the leader to stop and campaign again
+	mock.RemoveAll()
+	require.Eventually(t, func() bool {
+		return firstStopped.Load() == 1 && firstStarted.Load() == 2
+	}, 10*time.Second, 10*time.Millisecond)
+	assert.True(t, first.IsLeader())
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		second.Run(secondCtx)
+	}()
+
+	require.Eventually(t, func() bool {
+		return second.CurrentLeader() == "first"
+	}, 10*time.Second, 10*time.Millisecond)
+	assert.False(t, second.IsLeader())
+
+	// Stopping the elector hands leadership over
+	stopFirst()
+	require.Eventually(t, second.IsLeader, 10*time.Second, 10*time.Millisecond)
+	assert.Equal(t, "second", second.CurrentLeader())
+	assert.False(t, first.IsLeader())
+	assert.Equal(t, firstStarted.Load(), firstStopped.Load())
+
+	stopSecond()
+	wg.Wait()
+
+	assert.Nil(t, mock.Get("testing"))
+}
+
+func newTestElector(t *testing.T, client *storage.Client, id string) (*Elector, *atomic.Int32, *atomic.Int32) {
+	t.Helper()
+
+	var started, stopped atomic.Int32
+
+	lock := NewLock(client.Bucket("b"), id, "testing", time.Second, func(context.Context) Logger {
+		return loggerToTestingT{t}
+	})
+	elector, err := NewElector(lock, ElectorConfig{
+		RetryPeriod: 100 * time.Millisecond,
+		Callbacks: LeaderCallbacks{
+			OnStartedLeading: func(ctx context.Context) {
+				started.Add(1)
+				<-ctx.Done()
+			},
+			OnStoppedLeading: func() {
+				stopped.Add(1)
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	return elector, &started, &stopped
+}
+
+func TestElector_Run_LogsCampaignFailures(t *testing.T) {
+	mock := mock_gcs.NewServer("b", mock_gcs.WithFailOnObjectExistence(), mock_gcs.WithFailOnObjectName("testing"))
+	t.Cleanup(mock.Close)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	t.Cleanup(cancel)
+
+	client, err := mock.Client(ctx)
+	require.NoError(t, err)
+
+	client.SetRetry(storage.WithMaxAttempts(1))
+
+	logger := &recordingLogger{}
+	lock := NewLock(client.Bucket("b"), "id", "testing", time.Second, func(context.Context) Logger {
+		return logger
+	})
+	elector, err := NewElector(lock, ElectorConfig{
+		RetryPeriod: 50 * time.Millisecond,
+		Callbacks: LeaderCallbacks{
+			OnStartedLeading: func(ctx context.Context) {
+				<-ctx.Done()
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	runCtx, stop := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		elector.Run(runCtx)
+	}()
+
+	// Failures other than someone else leading are recorded each time leadership is campaigned for
+	require.Eventually(t, func() bool {
+		return logger.count("Failed to campaign for leadership") >= 2
+	}, 10*time.Second, 10*time.Millisecond)
+	assert.False(t, elector.IsLeader())
+
+	stop()
+	<-done
+}
+
+// recordingLogger records the messages of the errors logged through it.
+type recordingLogger struct {
+	m      sync.Mutex
+	errors []string
+}
+
+func (l *recordingLogger) Info(string, ...any) {}
+
+func (l *recordingLogger) Error(_ error, msg string, _ ...any) {
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	l.errors = append(l.errors, msg)
+}
+
+func (l *recordingLogger) count(msg string) int {
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	n := 0
+	for _, logged := range l.errors {
+		if logged == msg {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,62 @@
+package lock
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// ErrLockAbandoned is returned when the lock has been lost and the client should stop immediately.
+	ErrLockAbandoned = errors.New("lock abandoned")
+	// ErrLockHeld is matched by a LockHeldError, for when the lock is held by someone else.
+	ErrLockHeld = errors.New("lock held by someone else")
+	// ErrTimeout is matched by a TimeoutError, for when the lock couldn't be acquired in time.
+	ErrTimeout = errors.New("timed out acquiring lock")
+	// ErrNotOwner is returned when releasing a lock which is now owned by someone else.
+	ErrNotOwner = errors.New("unable to delete lock owned by someone else")
+)
+
+// LockHeldError describes who holds the lock, when it's held by someone else.
+type LockHeldError struct {
+	Holder
+}
+
+func (e *LockHeldError) Error() string {
+	if e.ExpiresAt.IsZero() {
+		return fmt.Sprintf("lock held by %s", e.Owner)
+	}
+
+	return fmt.Sprintf("lock held by %s until %s", e.Owner, e.ExpiresAt.Format(time.RFC3339))
+}
+
+// Is allows errors.Is to match ErrLockHeld.
+func (e *LockHeldError) Is(target error) bool {
+	return target == ErrLockHeld
+}
+
+// TimeoutError is returned by Lock when the lock couldn't be acquired before the timeout, summarising the attempts
+// made. It wraps the error from the last attempt, such as a LockHeldError, along with the error from the context.
+type TimeoutError struct {
+	Attempts int
+	Last     error
+	ctxErr   error
+}
+
+func (e *TimeoutError) Error() string {
+	if e.Last == nil {
+		return fmt.Sprintf("%s after %d attempts: %s", ErrTimeout, e.Attempts, e.ctxErr)
+	}
+
+	return fmt.Sprintf("%s after %d attempts: %s, last error: %s", ErrTimeout, e.Attempts, e.ctxErr, e.Last)
+}
+
+// Is allows errors.Is to match ErrTimeout.
+func (e *TimeoutError) Is(target error) bool {
+	return target == ErrTimeout
+}
+
+// Unwrap returns the error from the last attempt and the error from the context.
+func (e *TimeoutError) Unwrap() []error {
+	return []error{e.Last, e.ctxErr}
+}
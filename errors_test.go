@@ -0,0 +1,78 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLock_Lock_Errors(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	t.Cleanup(cancel)
+
+	backend := newMemoryBackend()
+	first, err := New(backend, "first", "testing", WithTTL(time.Minute))
+	require.NoError(t, err)
+	second, err := New(backend, "second", "testing", WithTTL(time.Minute), WithRetryInterval(10*time.Millisecond))
+	require.NoError(t, err)
+
+	require.NoError(t, first.Lock(ctx, time.Second))
+
+	err = second.Lock(ctx, 100*time.Millisecond)
+	assert.ErrorIs(t, err, ErrTimeout)
+	assert.ErrorIs(t, err, ErrLockHeld)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	var timeoutErr *TimeoutError
+	require.ErrorAs(t, err, &timeoutErr)
+	assert.Positive(t, timeoutErr.Attempts)
+
+	var heldErr *LockHeldError
+	require.ErrorAs(t, err, &heldErr)
+	assert.Equal(t, "first", heldErr.Owner)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), heldErr.ExpiresAt, 10*time.Second)
+	assert.ErrorContains(t, err, "lock held by first until")
+
+	// Only the last attempt is reported, not every one of them
+	assert.Equal(t, 1, strings.Count(err.Error(), "lock held by"))
+}
+
+func TestErrors_Error(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected string
+	}{
+		{
+			name:     "lock-held",
+			err:      &LockHeldError{Holder{Owner: "someone", ExpiresAt: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)}},
+			expected: "lock held by someone until 2024-01-02T03:04:05Z",
+		},
+		{
+			name:     "lock-held-unknown-expiry",
+			err:      &LockHeldError{Holder{Owner: "someone"}},
+			expected: "lock held by someone",
+		},
+		{
+			name:     "timeout",
+			err:      &TimeoutError{Attempts: 3, Last: errors.New("failed"), ctxErr: context.DeadlineExceeded},
+			expected: "timed out acquiring lock after 3 attempts: context deadline exceeded, last error: failed",
+		},
+		{
+			name:     "timeout-without-attempts",
+			err:      &TimeoutError{ctxErr: context.Canceled},
+			expected: "timed out acquiring lock after 0 attempts: context canceled",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.EqualError(t, test.err, test.expected)
+		})
+	}
+}
@@ -2,7 +2,6 @@ package lock
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"os"
 	"time"
@@ -55,23 +54,13 @@ func ExampleLock() {
 		}
 	}()
 
-	// Regularly refresh the lock. If we're told that the lock had to be abandoned, then cancel the context so any
-	// in-process work is stopped.
+	// Regularly refresh the lock in the background. If we're told that the lock had to be abandoned, then cancel the
+	// context so any in-process work is stopped. The refresher is stopped when the lock is unlocked.
+	lost := l.KeepAlive(ctx, 2*time.Second)
 	go func() {
-		t := time.Tick(2 * time.Second)
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			case <-t:
-				err := l.RefreshLock(ctx)
-				if err != nil {
-					if errors.Is(err, ErrLockAbandoned) {
-						cancel()
-					}
-					fmt.Printf("Failed to refresh the lock: %s", err)
-				}
-			}
+		if err, ok := <-lost; ok {
+			fmt.Printf("Lock lost: %s", err)
+			cancel()
 		}
 	}()
 	fmt.Println("hello")
@@ -0,0 +1,141 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// queueSuffix is appended to the path of the lock to give the prefix of the tickets waiting for it
+const queueSuffix = ".queue/"
+
+// errQueued is returned by an attempt to acquire the lock while others are ahead in the queue.
+var errQueued = errors.New("waiting in queue")
+
+// lockFairly acquires the lock in the same way as Lock, except that a ticket is first added to the queue and only the
+// client at the head of the queue tries to take the lock. The ticket is held in the same way as a lock, so the tickets
+// of clients which have died expire and are removed by the others.
+func (l *Lock) lockFairly(ctx context.Context, timeout time.Duration) error {
+	ticket := newLock(l.backend, l.identity, l.path+queueSuffix+l.identity, l.opts...)
+	defer func() {
+		if err := ticket.Unlock(context.WithoutCancel(ctx)); err != nil {
+			l.logger(ctx).Error(err, "Failed to leave queue", "path", l.path)
+		}
+	}()
+
+	var refreshed time.Time
+	return retry(ctx, l.clock, timeout, l.backoff, func(ctx context.Context) error {
+		// A lock handed off to this client is claimed without queueing, as it's only held for the claim window
+		handedOff, err := l.handedOff(ctx)
+		if err != nil {
+			return err
+		}
+		if handedOff {
+			return l.tryLock(ctx)
+		}
+
+		// Only refresh the ticket as often as the lock would be, rather than on every attempt
+		if l.clock.Now().Sub(refreshed) >= l.defaultRefreshInterval() {
+			if err := l.joinQueue(ctx, ticket); err != nil {
+				return err
+			}
+			refreshed = l.clock.Now()
+		}
+
+		head, err := l.queueHead(ctx, ticket.path)
+		if err != nil {
+			return err
+		}
+		if head != nil && head.Metadata[ownerMetadata] != l.identity {
+			return fmt.Errorf("%w behind %s", errQueued, head.Metadata[ownerMetadata])
+		}
+
+		return l.tryLock(ctx)
+	})
+}
+
+// tryLock makes a single attempt to acquire the lock, returning a LockHeldError if someone else holds it.
+func (l *Lock) tryLock(ctx context.Context) error {
+	acquired, holder, err := l.TryLock(ctx)
+	if err != nil {
+		return err
+	}
+	if !acquired {
+		return &LockHeldError{Holder: *holder}
+	}
+	return nil
+}
+
+// handedOff returns whether the lock has been handed off to this client and is waiting to be claimed.
+func (l *Lock) handedOff(ctx context.Context) (bool, error) {
+	attrs, err := l.backend.Attrs(ctx, l.path)
+	if errors.Is(err, ErrNotExist) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	return attrs.Metadata[ownerMetadata] == l.identity && attrs.Metadata[handoffMetadata] != "", nil
+}
+
+// joinQueue adds the ticket to the queue, or refreshes it if it's already there.
+func (l *Lock) joinQueue(ctx context.Context, ticket *Lock) error {
+	if ticket.FencingToken() != 0 {
+		err := ticket.RefreshLock(ctx)
+		if !errors.Is(err, ErrLockAbandoned) {
+			return err
+		}
+		// The ticket expired, so join the back of the queue again
+		l.logger(ctx).Info("Lost place in queue", "path", l.path)
+	}
+
+	return ticket.tryLock(ctx)
+}
+
+// queueHead returns the ticket which has been waiting longest, removing any tickets which have expired other than
+// this client's own.
+func (l *Lock) queueHead(ctx context.Context, ownTicket string) (*ObjectAttrs, error) {
+	paths, err := l.backend.List(ctx, l.path+queueSuffix)
+	if err != nil {
+		return nil, err
+	}
+
+	l.observed.retain(l.path+queueSuffix, paths)
+
+	var tickets []*ObjectAttrs
+	for _, path := range paths {
+		attrs, err := l.backend.Attrs(ctx, path)
+		if errors.Is(err, ErrNotExist) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		if stale, _ := l.observed.expired(path, attrs, l.clock.Now(), l.maxClockSkew); stale && path != ownTicket {
+			l.logger(ctx).Info("Queue ticket expired", "path", path)
+			err := l.backend.Delete(ctx, path, Conditions{GenerationMatch: attrs.Generation, MetagenerationMatch: attrs.Metageneration})
+			if err != nil && !errors.Is(err, ErrNotExist) && !errors.Is(err, ErrPreconditionFailed) {
+				return nil, err
+			}
+			continue
+		}
+
+		tickets = append(tickets, attrs)
+	}
+	if len(tickets) == 0 {
+		return nil, nil
+	}
+
+	// Backends which don't report the creation time, such as S3, order tickets by their generations instead, which are
+	// given out in the order the tickets were created
+	sort.SliceStable(tickets, func(i, j int) bool {
+		if !tickets[i].Created.Equal(tickets[j].Created) {
+			return tickets[i].Created.Before(tickets[j].Created)
+		}
+		return tickets[i].Generation < tickets[j].Generation
+	})
+
+	return tickets[0], nil
+}
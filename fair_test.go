@@ -0,0 +1,118 @@
+package lock
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLock_Lock_Fairness(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	t.Cleanup(cancel)
+
+	backend := newMemoryBackend()
+	newFairLock := func(identity string) *Lock {
+		l, err := New(backend, identity, "testing", WithTTL(time.Minute), WithRetryInterval(5*time.Millisecond), WithFairness(),
+			WithLogger(func(context.Context) Logger {
+				return loggerToTestingT{t}
+			}))
+		require.NoError(t, err)
+		return l
+	}
+
+	holder := newFairLock("holder")
+	require.NoError(t, holder.Lock(ctx, time.Second))
+	assert.Empty(t, mustList(ctx, t, backend, "testing"+queueSuffix), "the ticket should be removed once the lock is acquired")
+
+	// A waiter which has died is skipped once its ticket expires
+	_, err := backend.Create(ctx, "testing"+queueSuffix+"dead", ObjectAttrs{Metadata: map[string]string{
+		ownerMetadata: "dead",
+		ttlMetadata:   "1ms",
+	}})
+	require.NoError(t, err)
+
+	var mutex sync.Mutex
+	var order []string
+	var wg sync.WaitGroup
+	for _, identity := range []string{"first", "second", "third"} {
+		l := newFairLock(identity)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, l.Lock(ctx, 30*time.Second))
+
+			mutex.Lock()
+			order = append(order, identity)
+			mutex.Unlock()
+
+			time.Sleep(20 * time.Millisecond)
+			assert.NoError(t, l.Unlock(ctx))
+		}()
+
+		// Wait for the ticket, so the waiters join the queue in order
+		require.Eventually(t, func() bool {
+			_, err := backend.Attrs(ctx, "testing"+queueSuffix+identity)
+			return err == nil
+		}, 5*time.Second, time.Millisecond)
+	}
+
+	// Nobody can jump the queue while it's held
+	assert.ErrorIs(t, newFairLock("late").Lock(ctx, 50*time.Millisecond), errQueued)
+
+	require.NoError(t, holder.Unlock(ctx))
+	wg.Wait()
+
+	assert.Equal(t, []string{"first", "second", "third"}, order)
+	assert.Empty(t, mustList(ctx, t, backend, ""))
+}
+
+func TestLock_Handoff_WithFairness(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	t.Cleanup(cancel)
+
+	backend := newMemoryBackend()
+	newFairLock := func(identity string) *Lock {
+		l, err := New(backend, identity, "testing", WithTTL(time.Minute), WithRetryInterval(5*time.Millisecond), WithFairness(),
+			WithLogger(func(context.Context) Logger {
+				return loggerToTestingT{t}
+			}))
+		require.NoError(t, err)
+		return l
+	}
+
+	holder, successor, waiter := newFairLock("holder"), newFairLock("successor"), newFairLock("waiter")
+	require.NoError(t, holder.Lock(ctx, time.Second))
+
+	waited := make(chan error, 1)
+	go func() {
+		waited <- waiter.Lock(ctx, 30*time.Second)
+	}()
+	require.Eventually(t, func() bool {
+		_, err := backend.Attrs(ctx, "testing"+queueSuffix+"waiter")
+		return err == nil
+	}, 5*time.Second, time.Millisecond)
+
+	// The successor claims the lock without waiting behind those already queued
+	generation := holder.FencingToken()
+	require.NoError(t, holder.Handoff(ctx, "successor", time.Minute))
+	require.NoError(t, successor.Lock(ctx, time.Second))
+	assert.Equal(t, generation, successor.FencingToken())
+	assert.NotContains(t, backend.objects, "testing"+queueSuffix+"successor")
+
+	require.NoError(t, successor.Unlock(ctx))
+	require.NoError(t, <-waited)
+	require.NoError(t, waiter.Unlock(ctx))
+	assert.Empty(t, mustList(ctx, t, backend, ""))
+}
+
+func mustList(ctx context.Context, t *testing.T, backend Backend, prefix string) []string {
+	t.Helper()
+
+	paths, err := backend.List(ctx, prefix)
+	require.NoError(t, err)
+	return paths
+}
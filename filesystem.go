@@ -0,0 +1,312 @@
+package lock
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	filesystemGuardSuffix      = ".guard"
+	filesystemGenerationSuffix = ".generation"
+	filesystemTempSuffix       = ".tmp"
+	filesystemGuardRetry       = 5 * time.Millisecond
+)
+
+var _ Backend = filesystemBackend{}
+
+type filesystemBackend struct {
+	dir   string
+	clock Clock
+}
+
+// filesystemObject is the on-disk format of an object.
+type filesystemObject struct {
+	Metadata       map[string]string `json:"metadata,omitempty"`
+	CacheControl   string            `json:"cacheControl,omitempty"`
+	Generation     int64             `json:"generation"`
+	Metageneration int64             `json:"metageneration"`
+	Created        time.Time         `json:"created"`
+	Updated        time.Time         `json:"updated"`
+}
+
+// NewFilesystemBackend creates a Backend which stores lock objects as files within a directory, for local development
+// and single host deployments. Changes to an object are made while holding a lock on a guard file, and are written to a
+// temporary file which is renamed over the object so readers never see a partial write. The last generation used for
+// each object is kept in a sidecar file so that generations keep increasing across deletions. As everything runs on a
+// single host, the local clock is used for the creation and update times. Paths ending in the suffixes of these files
+// (.guard, .generation and .tmp) can't be used for locks.
+func NewFilesystemBackend(dir string) Backend {
+	return NewFilesystemBackendWithClock(dir, realClock{})
+}
+
+// NewFilesystemBackendWithClock creates a Backend in the same way as NewFilesystemBackend, using the Clock for the
+// creation and update times, such as to share a fake clock given to WithClock.
+func NewFilesystemBackendWithClock(dir string, clock Clock) Backend {
+	if clock == nil {
+		clock = realClock{}
+	}
+	return filesystemBackend{dir: dir, clock: clock}
+}
+
+func (f filesystemBackend) Create(ctx context.Context, path string, attrs ObjectAttrs) (*ObjectAttrs, error) { // nolint:gocritic
+	file, err := f.file(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var created *ObjectAttrs
+	err = f.guarded(ctx, file, func() error {
+		if _, err := os.Stat(file); err == nil {
+			return fmt.Errorf("%w: %s already exists", ErrPreconditionFailed, path)
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+
+		generation, err := f.nextGeneration(file)
+		if err != nil {
+			return err
+		}
+
+		now := f.clock.Now().UTC()
+		created = &ObjectAttrs{
+			Metadata:       attrs.Metadata,
+			CacheControl:   attrs.CacheControl,
+			Generation:     generation,
+			Metageneration: 1,
+			Created:        now,
+			Updated:        now,
+		}
+		return f.write(file, created)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return created, nil
+}
+
+func (f filesystemBackend) Update(
+	ctx context.Context, path string, conditions Conditions, metadata map[string]string,
+) (*ObjectAttrs, error) {
+	file, err := f.file(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var updated *ObjectAttrs
+	err = f.guarded(ctx, file, func() error {
+		current, err := f.read(file)
+		if err != nil {
+			return err
+		}
+		if err := checkConditions(current, conditions); err != nil {
+			return err
+		}
+
+		current.Metadata = metadata
+		current.Metageneration++
+		current.Updated = f.clock.Now().UTC()
+		updated = current
+		return f.write(file, updated)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return updated, nil
+}
+
+func (f filesystemBackend) Delete(ctx context.Context, path string, conditions Conditions) error {
+	file, err := f.file(path)
+	if err != nil {
+		return err
+	}
+
+	return f.guarded(ctx, file, func() error {
+		current, err := f.read(file)
+		if err != nil {
+			return err
+		}
+		if err := checkConditions(current, conditions); err != nil {
+			return err
+		}
+
+		return os.Remove(file)
+	})
+}
+
+func (f filesystemBackend) Attrs(_ context.Context, path string) (*ObjectAttrs, error) {
+	file, err := f.file(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return f.read(file)
+}
+
+func (f filesystemBackend) List(_ context.Context, prefix string) ([]string, error) {
+	// Only the directory the prefix is in, and those beneath it, can contain matching objects
+	root := f.dir
+	if dir := prefix[:strings.LastIndex(prefix, "/")+1]; dir != "" {
+		var err error
+		if root, err = f.file(dir); err != nil {
+			return nil, err
+		}
+	}
+
+	var paths []string
+	err := filepath.WalkDir(root, func(file string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if entry.IsDir() || isFilesystemSidecar(entry.Name()) {
+			return nil
+		}
+
+		rel, err := filepath.Rel(f.dir, file)
+		if err != nil {
+			return err
+		}
+		if name := filepath.ToSlash(rel); strings.HasPrefix(name, prefix) {
+			paths = append(paths, name)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	slices.Sort(paths)
+	return paths, nil
+}
+
+// isFilesystemSidecar reports whether the file is used to manage an object, rather than being an object itself.
+func isFilesystemSidecar(name string) bool {
+	return strings.HasSuffix(name, filesystemGuardSuffix) || strings.HasSuffix(name, filesystemGenerationSuffix) ||
+		strings.HasSuffix(name, filesystemTempSuffix)
+}
+
+// file returns the location of the object, making sure it can't escape the directory or be mistaken for the files used
+// to manage another object.
+func (f filesystemBackend) file(path string) (string, error) {
+	if !filepath.IsLocal(filepath.FromSlash(path)) {
+		return "", fmt.Errorf("invalid path %q", path)
+	}
+	if isFilesystemSidecar(path) {
+		return "", fmt.Errorf("invalid path %q: the suffix is reserved", path)
+	}
+
+	return filepath.Join(f.dir, filepath.FromSlash(path)), nil
+}
+
+// guarded runs fn while holding the guard for the file, waiting for any other holder to finish first.
+func (f filesystemBackend) guarded(ctx context.Context, file string, fn func() error) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0o750); err != nil {
+		return err
+	}
+
+	unlock, err := lockGuard(ctx, file+filesystemGuardSuffix)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	return fn()
+}
+
+func (f filesystemBackend) read(file string) (*ObjectAttrs, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("%w: %w", ErrNotExist, err)
+		}
+		return nil, err
+	}
+
+	var object filesystemObject
+	if err := json.Unmarshal(data, &object); err != nil {
+		return nil, fmt.Errorf("invalid lock object %s: %w", file, err)
+	}
+
+	return &ObjectAttrs{
+		Metadata:       object.Metadata,
+		CacheControl:   object.CacheControl,
+		Generation:     object.Generation,
+		Metageneration: object.Metageneration,
+		Created:        object.Created,
+		Updated:        object.Updated,
+	}, nil
+}
+
+func (f filesystemBackend) write(file string, attrs *ObjectAttrs) error {
+	data, err := json.Marshal(filesystemObject{
+		Metadata:       attrs.Metadata,
+		CacheControl:   attrs.CacheControl,
+		Generation:     attrs.Generation,
+		Metageneration: attrs.Metageneration,
+		Created:        attrs.Created,
+		Updated:        attrs.Updated,
+	})
+	if err != nil {
+		return err
+	}
+
+	return writeAtomically(file, data)
+}
+
+// nextGeneration increments the generation recorded in the sidecar of the file, which must be guarded.
+func (f filesystemBackend) nextGeneration(file string) (int64, error) {
+	sidecar := file + filesystemGenerationSuffix
+
+	var generation int64
+	data, err := os.ReadFile(sidecar)
+	switch {
+	case err == nil:
+		generation, err = strconv.ParseInt(string(data), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid generation in %s: %w", sidecar, err)
+		}
+	case !errors.Is(err, fs.ErrNotExist):
+		return 0, err
+	}
+
+	generation++
+	if err := writeAtomically(sidecar, []byte(strconv.FormatInt(generation, 10))); err != nil {
+		return 0, err
+	}
+
+	return generation, nil
+}
+
+// writeAtomically writes the data to a temporary file, which is then renamed over the original.
+func writeAtomically(file string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(file), filepath.Base(file)+".*"+filesystemTempSuffix)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), file)
+}
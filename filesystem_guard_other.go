@@ -0,0 +1,58 @@
+//go:build !unix
+
+package lock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"time"
+)
+
+// filesystemGuardTimeout is how long a guard can be held before it's assumed that the process holding it has died
+const filesystemGuardTimeout = 10 * time.Second
+
+// lockGuard creates the guard file with O_EXCL, waiting for any other holder to remove it first, and returns a function
+// to remove it. File locks aren't available, so a guard which has been held for too long is broken instead.
+func lockGuard(ctx context.Context, guard string) (func(), error) {
+	for {
+		g, err := os.OpenFile(guard, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+		if err == nil {
+			_ = g.Close()
+			return func() {
+				_ = os.Remove(guard)
+			}, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return nil, err
+		}
+
+		if info, err := os.Stat(guard); err == nil && time.Since(info.ModTime()) > filesystemGuardTimeout {
+			// The holder has most likely died, so break the guard and try again
+			breakGuard(guard)
+			continue
+		}
+
+		wait(ctx, realClock{}, filesystemGuardRetry)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// breakGuard removes a stale guard. It's renamed out of the way before being checked again, so that a guard which has
+// just been broken and taken by another waiter is put back rather than removed.
+func breakGuard(guard string) {
+	broken := fmt.Sprintf("%s.%d.tmp", guard, time.Now().UnixNano())
+	if err := os.Rename(guard, broken); err != nil {
+		return
+	}
+
+	if info, err := os.Stat(broken); err == nil && time.Since(info.ModTime()) <= filesystemGuardTimeout {
+		// Linking fails if the guard has been taken again since, rather than replacing it
+		_ = os.Link(broken, guard)
+	}
+	_ = os.Remove(broken)
+}
@@ -0,0 +1,41 @@
+//go:build unix
+
+package lock
+
+import (
+	"context"
+	"errors"
+	"os"
+	"syscall"
+)
+
+// lockGuard takes an exclusive lock on the guard file, waiting for any other holder to release it first, and returns a
+// function to release it. The lock is released by the operating system if the process holding it dies, so it never
+// needs to be broken. The guard file is left in place, as removing it would let a waiter lock a file which has already
+// been replaced.
+func lockGuard(ctx context.Context, guard string) (func(), error) {
+	g, err := os.OpenFile(guard, os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		err := syscall.Flock(int(g.Fd()), syscall.LOCK_EX|syscall.LOCK_NB) // nolint:gosec
+		if err == nil {
+			return func() {
+				// Closing the file releases the lock
+				_ = g.Close()
+			}, nil
+		}
+		if !errors.Is(err, syscall.EWOULDBLOCK) && !errors.Is(err, syscall.EINTR) {
+			_ = g.Close()
+			return nil, err
+		}
+
+		wait(ctx, realClock{}, filesystemGuardRetry)
+		if ctx.Err() != nil {
+			_ = g.Close()
+			return nil, ctx.Err()
+		}
+	}
+}
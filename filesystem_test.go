@@ -0,0 +1,207 @@
+package lock
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thg-ice/distributed-lock/mock_clock"
+)
+
+func TestFilesystemBackend(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	t.Cleanup(cancel)
+
+	dir := t.TempDir()
+	subject := NewFilesystemBackend(dir)
+
+	_, err := subject.Attrs(ctx, "path/testing")
+	assert.ErrorIs(t, err, ErrNotExist)
+
+	created, err := subject.Create(ctx, "path/testing", ObjectAttrs{CacheControl: "no-store", Metadata: map[string]string{"k": "v"}})
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), created.Created, time.Minute)
+	assert.Equal(t, &ObjectAttrs{
+		Metadata:       map[string]string{"k": "v"},
+		CacheControl:   "no-store",
+		Generation:     1,
+		Metageneration: 1,
+		Created:        created.Created,
+		Updated:        created.Created,
+	}, created)
+	assert.FileExists(t, filepath.Join(dir, "path", "testing"))
+
+	read, err := subject.Attrs(ctx, "path/testing")
+	require.NoError(t, err)
+	assert.Equal(t, created, read)
+
+	_, err = subject.Create(ctx, "path/testing", ObjectAttrs{})
+	assert.ErrorIs(t, err, ErrPreconditionFailed)
+
+	_, err = subject.Update(ctx, "path/testing", Conditions{MetagenerationMatch: 2}, map[string]string{"k": "other"})
+	assert.ErrorIs(t, err, ErrPreconditionFailed)
+
+	updated, err := subject.Update(ctx, "path/testing", Conditions{GenerationMatch: 1, MetagenerationMatch: 1}, map[string]string{"k": "other"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), updated.Metageneration)
+	assert.Equal(t, map[string]string{"k": "other"}, updated.Metadata)
+	assert.Equal(t, created.Created, updated.Created)
+	assert.False(t, updated.Updated.Before(created.Updated))
+
+	read, err = subject.Attrs(ctx, "path/testing")
+	require.NoError(t, err)
+	assert.Equal(t, updated, read)
+
+	assert.ErrorIs(t, subject.Delete(ctx, "path/testing", Conditions{MetagenerationMatch: 1}), ErrPreconditionFailed)
+	require.NoError(t, subject.Delete(ctx, "path/testing", Conditions{MetagenerationMatch: 2}))
+	assert.ErrorIs(t, subject.Delete(ctx, "path/testing", Conditions{MetagenerationMatch: 2}), ErrNotExist)
+	assert.NoFileExists(t, filepath.Join(dir, "path", "testing"))
+
+	// Recreating the object moves on to the next generation
+	recreated, err := subject.Create(ctx, "path/testing", ObjectAttrs{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), recreated.Generation)
+
+	for _, path := range []string{"list/b", "list/a/1", "listing", "other"} {
+		_, err = subject.Create(ctx, path, ObjectAttrs{})
+		require.NoError(t, err)
+	}
+	paths, err := subject.List(ctx, "list")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"list/a/1", "list/b", "listing"}, paths)
+	paths, err = subject.List(ctx, "list/")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"list/a/1", "list/b"}, paths)
+	paths, err = subject.List(ctx, "missing/")
+	require.NoError(t, err)
+	assert.Empty(t, paths)
+
+	_, err = subject.Attrs(ctx, "../escaped")
+	assert.ErrorContains(t, err, "invalid path")
+
+	// Paths can't collide with the files used to manage other objects
+	for _, path := range []string{"other.guard", "other.generation", "other.tmp"} {
+		_, err = subject.Create(ctx, path, ObjectAttrs{})
+		assert.ErrorContains(t, err, "invalid path", path)
+	}
+}
+
+func TestFilesystemBackend_Guard(t *testing.T) {
+	t.Run("waits-for-guard", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		t.Cleanup(cancel)
+
+		dir := t.TempDir()
+		unlock, err := lockGuard(ctx, filepath.Join(dir, "testing"+filesystemGuardSuffix))
+		require.NoError(t, err)
+		t.Cleanup(unlock)
+
+		_, err = NewFilesystemBackend(dir).Create(ctx, "testing", ObjectAttrs{})
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("ignores-abandoned-guard", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		t.Cleanup(cancel)
+
+		// A guard left behind by a process which died while holding it
+		dir := t.TempDir()
+		guard := filepath.Join(dir, "testing"+filesystemGuardSuffix)
+		require.NoError(t, os.WriteFile(guard, nil, 0o600))
+		modified := time.Now().Add(-time.Hour)
+		require.NoError(t, os.Chtimes(guard, modified, modified))
+
+		_, err := NewFilesystemBackend(dir).Create(ctx, "testing", ObjectAttrs{})
+		require.NoError(t, err)
+	})
+
+	t.Run("only-one-holder", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		t.Cleanup(cancel)
+
+		dir := t.TempDir()
+		subject := NewFilesystemBackend(dir).(filesystemBackend)
+
+		var holders, overlaps atomic.Int32
+		var wg sync.WaitGroup
+		for range 10 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				assert.NoError(t, subject.guarded(ctx, filepath.Join(dir, "testing"), func() error {
+					if holders.Add(1) > 1 {
+						overlaps.Add(1)
+					}
+					time.Sleep(time.Millisecond)
+					holders.Add(-1)
+					return nil
+				}))
+			}()
+		}
+		wg.Wait()
+		assert.Zero(t, overlaps.Load())
+	})
+}
+
+func TestLock_WithFilesystemBackend(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	t.Cleanup(cancel)
+
+	backend := NewFilesystemBackend(t.TempDir())
+	logger := func(context.Context) Logger {
+		return loggerToTestingT{t}
+	}
+	first := NewLockWithBackend(backend, "first", "path/to/file.lock", time.Minute, logger)
+	second := NewLockWithBackend(backend, "second", "path/to/file.lock", time.Minute, logger)
+
+	require.NoError(t, first.Lock(ctx, 100*time.Millisecond))
+	assert.ErrorIs(t, second.Lock(ctx, 100*time.Millisecond), context.DeadlineExceeded)
+	require.NoError(t, first.RefreshLock(ctx))
+	require.NoError(t, first.Unlock(ctx))
+
+	require.NoError(t, second.Lock(ctx, 100*time.Millisecond))
+	assert.Equal(t, int64(2), second.FencingToken())
+	require.NoError(t, second.Unlock(ctx))
+}
+
+func TestLock_WithFilesystemBackend_Clock(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	t.Cleanup(cancel)
+
+	start := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+	clock := mock_clock.NewClock(start)
+
+	backend := NewFilesystemBackendWithClock(t.TempDir(), clock)
+	newClockLock := func(identity string) *Lock {
+		l, err := New(backend, identity, "testing", WithTTL(time.Minute), WithClock(clock),
+			WithLogger(func(context.Context) Logger {
+				return loggerToTestingT{t}
+			}))
+		require.NoError(t, err)
+		return l
+	}
+
+	holder, other := newClockLock("holder"), newClockLock("other")
+	require.NoError(t, holder.Lock(ctx, time.Second))
+	attrs, err := backend.Attrs(ctx, "testing")
+	require.NoError(t, err)
+	assert.Equal(t, start, attrs.Created)
+	assert.Equal(t, start, attrs.Updated)
+
+	// The lock expires by the clock shared with the lock, rather than the system time
+	acquired, _, err := other.TryLock(ctx)
+	require.NoError(t, err)
+	assert.False(t, acquired)
+	clock.Advance(time.Minute + time.Millisecond)
+	acquired, _, err = other.TryLock(ctx)
+	require.NoError(t, err)
+	assert.True(t, acquired)
+	assert.ErrorIs(t, holder.RefreshLock(ctx), ErrLockAbandoned)
+}
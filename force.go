@@ -0,0 +1,97 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+const (
+	// tombstonePrefix is where tombstones are kept, away from the locks so that they aren't mistaken for another lease or
+	// ticket by anything listing the objects under a lock
+	tombstonePrefix = ".tombstones/"
+
+	brokenByMetadata           = "broken-by"
+	brokenAtMetadata           = "broken-at"
+	reasonMetadata             = "reason"
+	previousOwnerMetadata      = "previous-owner"
+	previousGenerationMetadata = "previous-generation"
+)
+
+// ForceRelease removes the lock at the path in the Backend whoever holds it, for when the holder is wedged but still
+// refreshing. Who broke the lock and why is recorded in a tombstone object, at the path under ".tombstones/", which is
+// overwritten by the next forced release. The holder's next RefreshLock returns ErrLockAbandoned. The details of the
+// lock which was removed are returned, or nil if the lock wasn't held.
+func ForceRelease(ctx context.Context, backend Backend, path, breaker, reason string) (*LockInfo, error) {
+	return forceRelease(ctx, backend, path, breaker, reason, realClock{})
+}
+
+func forceRelease(ctx context.Context, backend Backend, path, breaker, reason string, clock Clock) (*LockInfo, error) {
+	if breaker == "" || reason == "" {
+		return nil, errors.New("who is breaking the lock and why must be given")
+	}
+
+	info, err := inspect(ctx, backend, path, clock, 0)
+	if err != nil || info == nil {
+		return nil, err
+	}
+
+	// Only the generation is checked so that the holder refreshing the lock doesn't stop it being removed, while a new
+	// lock taken out in the meantime is left alone
+	if err := backend.Delete(ctx, path, Conditions{GenerationMatch: info.Generation}); err != nil {
+		return nil, fmt.Errorf("unable to remove lock held by %s: %w", info.Owner, err)
+	}
+
+	tombstone := map[string]string{
+		brokenByMetadata:           breaker,
+		brokenAtMetadata:           clock.Now().UTC().Format(time.RFC3339Nano),
+		reasonMetadata:             reason,
+		previousOwnerMetadata:      info.Owner,
+		previousGenerationMetadata: strconv.FormatInt(info.Generation, 10),
+	}
+	if err := writeTombstone(ctx, backend, tombstonePrefix+path, tombstone); err != nil {
+		return info, fmt.Errorf("removed lock held by %s but unable to record tombstone: %w", info.Owner, err)
+	}
+
+	return info, nil
+}
+
+// writeTombstone creates the tombstone, or overwrites the one left by the last forced release. The overwrite is
+// conditional on the tombstone which was read, as not every backend allows unconditional updates, retrying whenever
+// someone else changes it first.
+func writeTombstone(ctx context.Context, backend Backend, path string, tombstone map[string]string) error {
+	for {
+		_, err := backend.Create(ctx, path, ObjectAttrs{CacheControl: defaultCacheControl, Metadata: tombstone})
+		if !errors.Is(err, ErrPreconditionFailed) {
+			return err
+		}
+
+		attrs, err := backend.Attrs(ctx, path)
+		if errors.Is(err, ErrNotExist) {
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		_, err = backend.Update(ctx, path, Conditions{
+			GenerationMatch:     attrs.Generation,
+			MetagenerationMatch: attrs.Metageneration,
+		}, tombstone)
+		if !errors.Is(err, ErrPreconditionFailed) && !errors.Is(err, ErrNotExist) {
+			return err
+		}
+	}
+}
+
+// ForceRelease removes the lock whoever holds it, in the same way as the ForceRelease function, recording this Lock's
+// identity as having broken it.
+func (l *Lock) ForceRelease(ctx context.Context, reason string) (*LockInfo, error) {
+	info, err := forceRelease(ctx, l.backend, l.path, l.identity, reason, l.clock)
+	if info != nil {
+		l.logger(ctx).Info("Lock force released", "path", l.path, "previousOwner", info.Owner, "reason", reason)
+	}
+
+	return info, err
+}
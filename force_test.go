@@ -0,0 +1,119 @@
+package lock
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thg-ice/distributed-lock/mock_gcs"
+)
+
+func TestLock_ForceRelease(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	t.Cleanup(cancel)
+
+	backend := newMemoryBackend()
+	logger := WithLogger(func(context.Context) Logger {
+		return loggerToTestingT{t}
+	})
+	victim, err := New(backend, "victim", "testing", WithTTL(time.Minute), logger)
+	require.NoError(t, err)
+	admin, err := New(backend, "admin", "testing", WithTTL(time.Minute), logger)
+	require.NoError(t, err)
+
+	info, err := admin.ForceRelease(ctx, "nothing to break")
+	require.NoError(t, err)
+	assert.Nil(t, info)
+	assert.NotContains(t, backend.objects, tombstonePrefix+"testing")
+
+	_, err = admin.ForceRelease(ctx, "")
+	assert.ErrorContains(t, err, "who is breaking the lock and why must be given")
+
+	require.NoError(t, victim.Lock(ctx, time.Second))
+	require.NoError(t, victim.RefreshLock(ctx))
+	generation := victim.FencingToken()
+
+	for _, reason := range []string{"wedged", "wedged again"} {
+		info, err = admin.ForceRelease(ctx, reason)
+		require.NoError(t, err)
+		require.NotNil(t, info)
+		assert.Equal(t, "victim", info.Owner)
+		assert.Equal(t, generation, info.Generation)
+
+		assert.NotContains(t, backend.objects, "testing")
+		tombstone := backend.objects[tombstonePrefix+"testing"]
+		require.NotNil(t, tombstone)
+		assert.Equal(t, "admin", tombstone.Metadata[brokenByMetadata])
+		assert.Equal(t, reason, tombstone.Metadata[reasonMetadata])
+		assert.Equal(t, "victim", tombstone.Metadata[previousOwnerMetadata])
+		assert.Equal(t, strconv.FormatInt(generation, 10), tombstone.Metadata[previousGenerationMetadata])
+		assert.Contains(t, tombstone.Metadata, brokenAtMetadata)
+
+		assert.ErrorIs(t, victim.RefreshLock(ctx), ErrLockAbandoned)
+
+		require.NoError(t, victim.Lock(ctx, time.Second))
+		generation = victim.FencingToken()
+	}
+}
+
+func TestLock_ForceRelease_WithGCSBackend(t *testing.T) {
+	mock := mock_gcs.NewServer("b")
+	t.Cleanup(mock.Close)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	t.Cleanup(cancel)
+
+	client, err := mock.Client(ctx)
+	require.NoError(t, err)
+
+	backend := NewGCSBackend(client.Bucket("b"))
+	logger := WithLogger(func(context.Context) Logger {
+		return loggerToTestingT{t}
+	})
+	victim, err := New(backend, "victim", "testing", WithTTL(time.Minute), logger)
+	require.NoError(t, err)
+	admin, err := New(backend, "admin", "testing", WithTTL(time.Minute), logger)
+	require.NoError(t, err)
+
+	// The second tombstone overwrites the first, which GCS only allows with preconditions
+	for _, reason := range []string{"wedged", "wedged again"} {
+		require.NoError(t, victim.Lock(ctx, time.Second))
+
+		info, err := admin.ForceRelease(ctx, reason)
+		require.NoError(t, err)
+		require.NotNil(t, info)
+		assert.Equal(t, "victim", info.Owner)
+
+		tombstone := mock.Get(tombstonePrefix + "testing")
+		require.NotNil(t, tombstone)
+		assert.Equal(t, reason, tombstone.Metadata[reasonMetadata])
+		assert.Equal(t, strconv.FormatInt(info.Generation, 10), tombstone.Metadata[previousGenerationMetadata])
+	}
+}
+
+func TestRWLock_ForceReleaseReader(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	t.Cleanup(cancel)
+
+	backend := newMemoryBackend()
+	reader, err := NewRWLock(backend, "reader", "dataset", WithTTL(time.Minute))
+	require.NoError(t, err)
+	writer, err := NewRWLock(backend, "writer", "dataset", WithTTL(time.Minute), WithRetryInterval(10*time.Millisecond))
+	require.NoError(t, err)
+
+	require.NoError(t, reader.RLock(ctx, time.Second))
+	info, err := ForceRelease(ctx, backend, "dataset/readers/reader", "admin", "wedged")
+	require.NoError(t, err)
+	require.NotNil(t, info)
+	assert.Equal(t, "reader", info.Owner)
+
+	// The tombstone isn't taken for another reader's lease, so it's kept once the writer has the lock
+	require.NoError(t, writer.Lock(ctx, time.Second))
+	tombstone := backend.objects[tombstonePrefix+"dataset/readers/reader"]
+	require.NotNil(t, tombstone)
+	assert.Equal(t, "admin", tombstone.Metadata[brokenByMetadata])
+	assert.Equal(t, "reader", tombstone.Metadata[previousOwnerMetadata])
+}
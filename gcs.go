@@ -0,0 +1,121 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/iterator"
+)
+
+var _ Backend = gcsBackend{}
+
+type gcsBackend struct {
+	bucket *storage.BucketHandle
+}
+
+// NewGCSBackend creates a Backend which stores lock objects in a Google Cloud Storage bucket.
+func NewGCSBackend(bucket *storage.BucketHandle) Backend {
+	return gcsBackend{bucket: bucket}
+}
+
+func (g gcsBackend) Create(ctx context.Context, path string, attrs ObjectAttrs) (*ObjectAttrs, error) { // nolint:gocritic
+	w := g.bucket.Object(path).If(storage.Conditions{DoesNotExist: true}).NewWriter(ctx)
+	w.CacheControl = attrs.CacheControl
+	w.Metadata = attrs.Metadata
+
+	if err := w.Close(); err != nil {
+		return nil, gcsError(err)
+	}
+
+	return fromGCSAttrs(w.Attrs()), nil
+}
+
+func (g gcsBackend) Update(ctx context.Context, path string, conditions Conditions, metadata map[string]string) (*ObjectAttrs, error) {
+	attrs, err := g.object(path, conditions).Update(ctx, storage.ObjectAttrsToUpdate{Metadata: metadata})
+	if err != nil {
+		return nil, gcsError(err)
+	}
+
+	return fromGCSAttrs(attrs), nil
+}
+
+func (g gcsBackend) Delete(ctx context.Context, path string, conditions Conditions) error {
+	return gcsError(g.object(path, conditions).Delete(ctx))
+}
+
+func (g gcsBackend) Attrs(ctx context.Context, path string) (*ObjectAttrs, error) {
+	attrs, err := g.bucket.Object(path).Attrs(ctx)
+	if err != nil {
+		return nil, gcsError(err)
+	}
+
+	return fromGCSAttrs(attrs), nil
+}
+
+func (g gcsBackend) List(ctx context.Context, prefix string) ([]string, error) {
+	query := &storage.Query{Prefix: prefix}
+	if err := query.SetAttrSelection([]string{"Name"}); err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	it := g.bucket.Objects(ctx, query)
+	for {
+		attrs, err := it.Next()
+		if errors.Is(err, iterator.Done) {
+			break
+		}
+		if err != nil {
+			return nil, gcsError(err)
+		}
+		paths = append(paths, attrs.Name)
+	}
+
+	return paths, nil
+}
+
+func (g gcsBackend) object(path string, conditions Conditions) *storage.ObjectHandle {
+	o := g.bucket.Object(path)
+	if conditions == (Conditions{}) {
+		// The client rejects empty conditions
+		return o
+	}
+
+	return o.If(storage.Conditions{
+		GenerationMatch:     conditions.GenerationMatch,
+		MetagenerationMatch: conditions.MetagenerationMatch,
+	})
+}
+
+func fromGCSAttrs(attrs *storage.ObjectAttrs) *ObjectAttrs {
+	return &ObjectAttrs{
+		Metadata:       attrs.Metadata,
+		CacheControl:   attrs.CacheControl,
+		Generation:     attrs.Generation,
+		Metageneration: attrs.Metageneration,
+		Created:        attrs.Created,
+		Updated:        attrs.Updated,
+	}
+}
+
+// gcsError maps errors from Google Cloud Storage on to the errors expected from a Backend, keeping the original error
+// so the details aren't lost.
+func gcsError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var gErr *googleapi.Error
+	switch {
+	case errors.Is(err, storage.ErrObjectNotExist):
+		return fmt.Errorf("%w: %w", ErrNotExist, err)
+	case errors.As(err, &gErr) && gErr.Code == http.StatusPreconditionFailed:
+		return fmt.Errorf("%w: %w", ErrPreconditionFailed, err)
+	default:
+		return err
+	}
+}
@@ -0,0 +1,74 @@
+package lock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thg-ice/distributed-lock/mock_gcs"
+)
+
+func TestGCSBackend(t *testing.T) {
+	mock := mock_gcs.NewServer("b", mock_gcs.WithFailOnObjectExistence())
+	t.Cleanup(mock.Close)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	t.Cleanup(cancel)
+
+	client, err := mock.Client(ctx)
+	require.NoError(t, err)
+
+	subject := NewGCSBackend(client.Bucket("b"))
+
+	_, err = subject.Attrs(ctx, "testing")
+	assert.ErrorIs(t, err, ErrNotExist)
+
+	created, err := subject.Create(ctx, "testing", ObjectAttrs{CacheControl: "no-store", Metadata: map[string]string{"k": "v"}})
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), created.Created, time.Minute)
+	assert.Equal(t, &ObjectAttrs{
+		Metadata:       map[string]string{"k": "v"},
+		CacheControl:   "no-store",
+		Generation:     1,
+		Metageneration: 1,
+		Created:        created.Created,
+		Updated:        created.Created,
+	}, created)
+
+	_, err = subject.Create(ctx, "testing", ObjectAttrs{})
+	assert.ErrorIs(t, err, ErrPreconditionFailed)
+
+	_, err = subject.Update(ctx, "testing", Conditions{MetagenerationMatch: 2}, map[string]string{"k": "other"})
+	assert.ErrorIs(t, err, ErrPreconditionFailed)
+
+	updated, err := subject.Update(ctx, "testing", Conditions{MetagenerationMatch: 1}, map[string]string{"k": "other"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), updated.Metageneration)
+	assert.Equal(t, map[string]string{"k": "other"}, updated.Metadata)
+	assert.Equal(t, created.Created, updated.Created)
+	assert.False(t, updated.Updated.Before(created.Updated))
+
+	read, err := subject.Attrs(ctx, "testing")
+	require.NoError(t, err)
+	assert.Equal(t, updated, read)
+
+	assert.ErrorIs(t, subject.Delete(ctx, "testing", Conditions{MetagenerationMatch: 1}), ErrPreconditionFailed)
+	require.NoError(t, subject.Delete(ctx, "testing", Conditions{MetagenerationMatch: 2}))
+	assert.ErrorIs(t, subject.Delete(ctx, "testing", Conditions{MetagenerationMatch: 2}), ErrNotExist)
+
+	for _, path := range []string{"list/b", "list/a/1", "listing", "other"} {
+		_, err = subject.Create(ctx, path, ObjectAttrs{})
+		require.NoError(t, err)
+	}
+	paths, err := subject.List(ctx, "list")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"list/a/1", "list/b", "listing"}, paths)
+	paths, err = subject.List(ctx, "list/")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"list/a/1", "list/b"}, paths)
+	paths, err = subject.List(ctx, "missing/")
+	require.NoError(t, err)
+	assert.Empty(t, paths)
+}
@@ -0,0 +1,53 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// handoffMetadata records who handed the lock off, until the successor claims it and it's cleared
+const handoffMetadata = "handoff-from"
+
+// Handoff transfers the lock to the successor, rather than releasing it for anyone to take, such as when shutting down
+// gracefully. The lock is rewritten with the successor as the owner, as long as it's still held by this client, and
+// only lasts for the claim window. The successor claims the lock by calling Lock or TryLock within the claim window,
+// without queueing under WithFairness, after which it's held as normal, otherwise the lock expires. The successor keeps
+// the same fencing token, so work done under the lock must have stopped before handing it off. Any refresher started
+// by KeepAlive is stopped first, and ErrLockAbandoned is returned if the lock had already been lost.
+func (l *Lock) Handoff(ctx context.Context, successor string, claimWindow time.Duration) error {
+	if successor == "" || successor == l.identity {
+		return errors.New("successor must be a different identity")
+	}
+	if claimWindow <= 0 {
+		return errors.New("claim window must be positive")
+	}
+
+	l.stopRefreshing()
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if !l.refreshMetadata {
+		return ErrLockAbandoned
+	}
+
+	metadata := l.metadataFor(successor, claimWindow)
+	metadata[handoffMetadata] = l.identity
+
+	_, err := l.backend.Update(ctx, l.path, Conditions{
+		GenerationMatch:     l.latestGeneration,
+		MetagenerationMatch: l.latestMetadataGeneration,
+	}, metadata)
+	if err != nil {
+		if errors.Is(err, ErrNotExist) || errors.Is(err, ErrPreconditionFailed) {
+			l.refreshMetadata = false
+			return ErrLockAbandoned
+		}
+		return err
+	}
+
+	l.logger(ctx).Info("Handed off lock", "path", l.path, "successor", successor)
+	l.refreshMetadata = false
+	return nil
+}
@@ -0,0 +1,141 @@
+package lock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thg-ice/distributed-lock/mock_gcs"
+)
+
+func TestLock_Handoff(t *testing.T) {
+	tests := []struct {
+		name              string
+		successor         string
+		claimWindow       time.Duration
+		skipLock          bool
+		waitBeforeClaim   time.Duration
+		expectedErr       string
+		expectedNewHolder string
+	}{
+		{
+			name:              "successor-claims-lock",
+			successor:         "successor",
+			claimWindow:       time.Minute,
+			expectedNewHolder: "successor",
+		},
+		{
+			name:              "lock-expires-if-not-claimed",
+			successor:         "successor",
+			claimWindow:       200 * time.Millisecond,
+			waitBeforeClaim:   300 * time.Millisecond,
+			expectedNewHolder: "someone-else",
+		},
+		{
+			name:        "lock-not-held",
+			successor:   "successor",
+			claimWindow: time.Minute,
+			skipLock:    true,
+			expectedErr: ErrLockAbandoned.Error(),
+		},
+		{
+			name:        "missing-successor",
+			claimWindow: time.Minute,
+			expectedErr: "successor must be a different identity",
+		},
+		{
+			name:        "successor-is-self",
+			successor:   "id",
+			claimWindow: time.Minute,
+			expectedErr: "successor must be a different identity",
+		},
+		{
+			name:        "invalid-claim-window",
+			successor:   "successor",
+			expectedErr: "claim window must be positive",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+			t.Cleanup(cancel)
+
+			backend := newMemoryBackend()
+			newLock := func(identity string) *Lock {
+				l, err := New(backend, identity, "testing", WithTTL(time.Minute), WithRetryInterval(5*time.Millisecond),
+					WithLogger(func(context.Context) Logger {
+						return loggerToTestingT{t}
+					}))
+				require.NoError(t, err)
+				return l
+			}
+			subject, successor, someoneElse := newLock("id"), newLock("successor"), newLock("someone-else")
+
+			if !test.skipLock {
+				require.NoError(t, subject.Lock(ctx, time.Second))
+			}
+
+			err := subject.Handoff(ctx, test.successor, test.claimWindow)
+			if test.expectedErr != "" {
+				assert.EqualError(t, err, test.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "successor", backend.objects["testing"].Metadata[ownerMetadata])
+			assert.Equal(t, "id", backend.objects["testing"].Metadata[handoffMetadata])
+
+			// The old holder no longer holds the lock, and nobody else can take it during the claim window
+			assert.Zero(t, subject.FencingToken())
+			assert.ErrorIs(t, subject.Unlock(ctx), ErrNotOwner)
+			assert.ErrorIs(t, someoneElse.Lock(ctx, 20*time.Millisecond), ErrLockHeld)
+
+			time.Sleep(test.waitBeforeClaim)
+			newHolder := map[string]*Lock{"successor": successor, "someone-else": someoneElse}[test.expectedNewHolder]
+			require.NoError(t, newHolder.Lock(ctx, time.Second))
+			require.NoError(t, newHolder.RefreshLock(ctx))
+
+			attrs, err := backend.Attrs(ctx, "testing")
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedNewHolder, attrs.Metadata[ownerMetadata])
+			assert.Empty(t, attrs.Metadata[handoffMetadata])
+			assert.Equal(t, "1m0s", attrs.Metadata[ttlMetadata])
+		})
+	}
+}
+
+func TestLock_Handoff_WithGCSBackend(t *testing.T) {
+	mock := mock_gcs.NewServer("b")
+	t.Cleanup(mock.Close)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	t.Cleanup(cancel)
+
+	client, err := mock.Client(ctx)
+	require.NoError(t, err)
+
+	backend := NewGCSBackend(client.Bucket("b"))
+	newLock := func(identity string) *Lock {
+		l, err := New(backend, identity, "testing", WithTTL(time.Minute), WithRetryInterval(5*time.Millisecond),
+			WithLogger(func(context.Context) Logger {
+				return loggerToTestingT{t}
+			}))
+		require.NoError(t, err)
+		return l
+	}
+	subject, successor := newLock("id"), newLock("successor")
+
+	require.NoError(t, subject.Lock(ctx, time.Second))
+	require.NoError(t, subject.Handoff(ctx, "successor", time.Minute))
+	require.NoError(t, successor.Lock(ctx, time.Second))
+	require.NoError(t, successor.RefreshLock(ctx))
+
+	// GCS keeps keys missing from an update, so the claimed lock mustn't look as though it's still being handed off
+	assert.Equal(t, "successor", mock.Get("testing").Metadata[ownerMetadata])
+	assert.Empty(t, mock.Get("testing").Metadata[handoffMetadata])
+	handedOff, err := successor.handedOff(ctx)
+	require.NoError(t, err)
+	assert.False(t, handedOff)
+}
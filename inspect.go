@@ -0,0 +1,57 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"time"
+)
+
+// LockInfo describes the current state of a lock object, as read from the Backend.
+type LockInfo struct {
+	Holder
+	// Metadata holds any extra metadata added with WithMetadata, as well as the metadata used by the lock itself
+	Metadata       map[string]string
+	Generation     int64
+	Metageneration int64
+	Created        time.Time
+	Updated        time.Time
+	// Stale is set if the lock has expired, or its expiry couldn't be worked out, so will be removed by a client trying
+	// to acquire it once that client has seen it go unchanged for its TTL
+	Stale bool
+}
+
+// Inspect reads who holds the lock at the path in the Backend and until when, without trying to acquire it. If the
+// lock isn't held, nil is returned.
+func Inspect(ctx context.Context, backend Backend, path string) (*LockInfo, error) {
+	return inspect(ctx, backend, path, realClock{}, 0)
+}
+
+// Inspect reads who holds the lock and until when, without trying to acquire it, allowing for the configured maximum
+// clock skew when deciding whether it's stale. If the lock isn't held, nil is returned.
+func (l *Lock) Inspect(ctx context.Context) (*LockInfo, error) {
+	return inspect(ctx, l.backend, l.path, l.clock, l.maxClockSkew)
+}
+
+func inspect(ctx context.Context, backend Backend, path string, clock Clock, maxClockSkew time.Duration) (*LockInfo, error) {
+	attrs, err := backend.Attrs(ctx, path)
+	if err != nil {
+		if errors.Is(err, ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	holder := holderOf(attrs)
+	stale, _ := isStale(attrs, clock.Now(), maxClockSkew)
+
+	return &LockInfo{
+		Holder:         *holder,
+		Metadata:       maps.Clone(attrs.Metadata),
+		Generation:     attrs.Generation,
+		Metageneration: attrs.Metageneration,
+		Created:        attrs.Created,
+		Updated:        attrs.Updated,
+		Stale:          stale,
+	}, nil
+}
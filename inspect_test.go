@@ -0,0 +1,81 @@
+package lock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInspect(t *testing.T) {
+	tests := []struct {
+		name          string
+		skipLock      bool
+		ttl           time.Duration
+		maxClockSkew  time.Duration
+		expectedStale bool
+	}{
+		{
+			name:     "not-held",
+			skipLock: true,
+		},
+		{
+			name: "held",
+			ttl:  time.Minute,
+		},
+		{
+			name:          "expired",
+			ttl:           time.Millisecond,
+			expectedStale: true,
+		},
+		{
+			name:         "expired-within-clock-skew",
+			ttl:          time.Millisecond,
+			maxClockSkew: time.Minute,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+			t.Cleanup(cancel)
+
+			backend := newMemoryBackend()
+			if !test.skipLock {
+				holder, err := New(backend, "holder", "testing", WithTTL(test.ttl), WithMetadata(map[string]string{"host": "example"}))
+				require.NoError(t, err)
+				require.NoError(t, holder.Lock(ctx, time.Second))
+				time.Sleep(10 * time.Millisecond)
+			}
+
+			subject, err := New(backend, "id", "testing", WithMaxClockSkew(test.maxClockSkew))
+			require.NoError(t, err)
+
+			info, err := subject.Inspect(ctx)
+			require.NoError(t, err)
+
+			if test.skipLock {
+				assert.Nil(t, info)
+				return
+			}
+
+			attrs := backend.objects["testing"]
+			require.NotNil(t, info)
+			assert.Equal(t, "holder", info.Owner)
+			assert.Equal(t, attrs.Updated.Add(test.ttl), info.ExpiresAt)
+			assert.Equal(t, "example", info.Metadata["host"])
+			assert.Equal(t, attrs.Generation, info.Generation)
+			assert.Equal(t, attrs.Metageneration, info.Metageneration)
+			assert.Equal(t, attrs.Created, info.Created)
+			assert.Equal(t, attrs.Updated, info.Updated)
+			assert.Equal(t, test.expectedStale, info.Stale)
+
+			// Inspecting without a Lock doesn't allow for any clock skew
+			info, err = Inspect(ctx, backend, "testing")
+			require.NoError(t, err)
+			assert.Equal(t, test.ttl < time.Minute, info.Stale)
+		})
+	}
+}
@@ -1,4 +1,5 @@
-// Package lock provides a distributed locking algorithm backed by Google Cloud Storage. See
+// Package lock provides a distributed locking algorithm backed by Google Cloud Storage, or any other Backend offering
+// the same conditional write primitives. See
 // https://www.joyfulbikeshedding.com/blog/2021-05-19-robust-distributed-locking-algorithm-based-on-google-cloud-storage.html
 // for more details on the general design.
 package lock
@@ -6,54 +7,63 @@ package lock
 import (
 	"context"
 	"errors"
-	"net/http"
+	"maps"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
 	"cloud.google.com/go/storage"
-	"google.golang.org/api/googleapi"
-)
-
-var (
-	// ErrLockAbandoned is returned when the lock has been lost and the client should stop immediately.
-	ErrLockAbandoned          = errors.New("lock abandoned")
-	errLockOwnedBySomeoneElse = errors.New("unable to delete lock owned by someone else")
 )
 
 const (
-	maxRefreshFailures = 3
-	ownerMetadata      = "owner"
-	expiresAtMetadata  = "expires-at"
+	ownerMetadata     = "owner"
+	expiresAtMetadata = "expires-at"
+	ttlMetadata       = "ttl"
 )
 
-// Lock provides a lock based off of a Google Cloud Storage object, without requiring communication between clients.
+// Lock provides a lock based off of an object in a Backend such as Google Cloud Storage, without requiring
+// communication between clients.
 type Lock struct {
-	bucket   *storage.BucketHandle
+	backend  Backend
 	path     string
 	identity string
 	ttl      time.Duration
 	logger   func(ctx context.Context) Logger
+	clock    Clock
+
+	backoff            Backoff
+	refreshInterval    time.Duration
+	maxRefreshFailures uint
+	maxClockSkew       time.Duration
+	cacheControl       string
+	extraMetadata      map[string]string
+	fair               bool
+	resume             bool
+	opts               []Option
+
+	// observed is shared with the Locks used for reader leases, which are created afresh each time they're checked
+	observed *observations
 
 	mutex           sync.Mutex
 	refreshMetadata bool
 	refreshFailures uint
+	stopKeepAlive   func()
 
 	latestGeneration         int64
 	latestMetadataGeneration int64
 }
 
 // NewLock creates a new distributed lock instance backed by Google Cloud Storage.
-func NewLock(bucket *storage.BucketHandle, id, path string, ttl time.Duration, logContext func(context.Context) Logger) *Lock {
-	return &Lock{
-		bucket:                   bucket,
-		path:                     path,
-		identity:                 id,
-		ttl:                      ttl,
-		logger:                   logContext,
-		mutex:                    sync.Mutex{},
-		refreshMetadata:          false,
-		latestMetadataGeneration: 0,
-	}
+func NewLock(bucket *storage.BucketHandle, id, path string, ttl time.Duration, logContext func(context.Context) Logger,
+	opts ...Option) *Lock {
+	return NewLockWithBackend(NewGCSBackend(bucket), id, path, ttl, logContext, opts...)
+}
+
+// NewLockWithBackend creates a new distributed lock instance backed by the given Backend.
+func NewLockWithBackend(backend Backend, id, path string, ttl time.Duration, logContext func(context.Context) Logger,
+	opts ...Option) *Lock {
+	return newLock(backend, id, path, append([]Option{WithTTL(ttl), WithLogger(logContext)}, opts...)...)
 }
 
 // Logger defines the interface for logging within the lock implementation.
@@ -62,42 +72,213 @@ type Logger interface {
 	Error(err error, msg string, keysAndValues ...any)
 }
 
-// Lock will attempt to acquire the configured lock until the context has timed out. The caller is expected to
-// frequently call RefreshLock while holding the lock and Unlock when the lock is no longer needed.
+// Lock will attempt to acquire the configured lock until the context has timed out, waiting between attempts as
+// decided by the configured Backoff. If the lock isn't acquired in time, a TimeoutError is returned which wraps the
+// error from the last attempt, such as a LockHeldError when someone else holds the lock, skipping over an attempt cut
+// short by the timeout. With WithFairness, clients take turns in the order they started waiting. The caller is expected
+// to frequently call RefreshLock while holding the lock and Unlock when the lock is no longer needed.
 func (l *Lock) Lock(ctx context.Context, timeout time.Duration) error {
-	ctx, cancel := context.WithTimeout(ctx, timeout)
-	defer cancel()
-
-	var errs []error
-	for {
-		select {
-		case <-ctx.Done():
-			return errors.Join(append(errs, ctx.Err())...)
-		default:
-			err := l.createLock(ctx)
-			if err == nil {
+	if l.fair {
+		return l.lockFairly(ctx, timeout)
+	}
+
+	return retry(ctx, l.clock, timeout, l.backoff, func(ctx context.Context) error {
+		err := l.createLock(ctx)
+		if err == nil {
+			return nil
+		}
+
+		if errors.Is(err, ErrPreconditionFailed) {
+			resumed, holder, checkErr := l.resumeOrDeleteStale(ctx)
+			if resumed {
 				return nil
 			}
-
-			var gErr *googleapi.Error
-			if errors.As(err, &gErr) && gErr.Code == http.StatusPreconditionFailed {
-				if err := l.deleteLockIfStale(ctx); err != nil {
-					return err
-				}
+			if checkErr != nil && ctx.Err() == nil {
+				return &permanentError{err: checkErr}
+			}
+			if holder != nil {
+				err = &LockHeldError{Holder: *holder}
 			}
-			l.logger(ctx).Error(err, "Failed to acquire lock", "path", l.path)
-			errs = append(errs, err)
 		}
+		l.logger(ctx).Error(err, "Failed to acquire lock", "path", l.path)
+		return err
+	})
+}
+
+// Holder describes who holds a lock, and when their lock expires unless it's refreshed.
+type Holder struct {
+	Owner     string
+	ExpiresAt time.Time
+}
+
+// TryLock makes a single attempt to acquire the lock without waiting, returning whether it was acquired. If the lock is
+// held by someone else, their details are returned instead. A lock left behind by an expired holder, or by this
+// identity, is removed and the attempt made once more. Any queue used by WithFairness is ignored.
+func (l *Lock) TryLock(ctx context.Context) (bool, *Holder, error) {
+	err := l.createLock(ctx)
+	if !errors.Is(err, ErrPreconditionFailed) {
+		return err == nil, nil, err
+	}
 
-		time.Sleep(100 * time.Millisecond)
+	resumed, holder, err := l.resumeOrDeleteStale(ctx)
+	if err != nil || resumed || holder != nil {
+		return resumed, holder, err
 	}
+
+	err = l.createLock(ctx)
+	if !errors.Is(err, ErrPreconditionFailed) {
+		return err == nil, nil, err
+	}
+
+	// Someone else took the lock after the stale one was removed
+	attrs, err := l.backend.Attrs(ctx, l.path)
+	if err != nil {
+		return false, nil, err
+	}
+	return false, holderOf(attrs), nil
 }
 
-// Unlock will attempt to release the acquired lock.
+// Unlock will attempt to release the acquired lock. Any refresher started by KeepAlive is stopped first. ErrNotOwner is
+// returned if the lock has since been taken by someone else.
 func (l *Lock) Unlock(ctx context.Context) error {
+	l.stopRefreshing()
 	return l.deleteLock(ctx, nil, nil, true)
 }
 
+// KeepAlive starts a background refresher which calls RefreshLock every interval until Unlock is called or the context
+// is done. A zero interval uses a default derived from the TTL. If the lock is lost, ErrLockAbandoned is sent on the
+// returned channel, which is closed once the refresher has stopped. Calling KeepAlive again replaces any existing
+// refresher.
+func (l *Lock) KeepAlive(ctx context.Context, interval time.Duration) <-chan error {
+	return l.keepAlive(ctx, interval, nil, nil)
+}
+
+// LockContext will acquire the lock in the same way as Lock, and then keep it alive in the background. The returned
+// context is cancelled with ErrLockAbandoned as its cause as soon as the lock is lost, either because RefreshLock
+// reports that it has been abandoned or because the TTL elapsed without a successful refresh. The returned release
+// function cancels the context and unlocks the lock, and should always be called once the work is finished.
+func (l *Lock) LockContext(ctx context.Context, timeout time.Duration) (context.Context, func() error, error) {
+	started := l.clock.Now()
+	if err := l.Lock(ctx, timeout); err != nil {
+		return nil, nil, err
+	}
+
+	lockCtx, cancel := context.WithCancelCause(ctx)
+	expire := func() {
+		cancel(ErrLockAbandoned)
+	}
+
+	var expiryMutex sync.Mutex
+	stopExpiry := l.clock.AfterFunc(started.Add(l.ttl).Sub(l.clock.Now()), expire)
+
+	lost := l.keepAlive(lockCtx, 0, func(refreshed time.Time) {
+		expiryMutex.Lock()
+		defer expiryMutex.Unlock()
+
+		stopExpiry()
+		stopExpiry = l.clock.AfterFunc(refreshed.Add(l.ttl).Sub(l.clock.Now()), expire)
+	}, nil)
+	go func() {
+		if err, ok := <-lost; ok {
+			cancel(err)
+		}
+	}()
+
+	release := func() error {
+		expiryMutex.Lock()
+		stopExpiry()
+		expiryMutex.Unlock()
+
+		cancel(nil)
+		return l.Unlock(context.WithoutCancel(ctx))
+	}
+
+	return lockCtx, release, nil
+}
+
+// keepAlive runs the refresher for KeepAlive, calling onRefresh with the time each successful refresh was started, and
+// onLost once the lock is lost, before ErrLockAbandoned is sent.
+func (l *Lock) keepAlive(ctx context.Context, interval time.Duration, onRefresh func(time.Time), onLost func()) <-chan error {
+	if interval <= 0 {
+		interval = l.defaultRefreshInterval()
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	lost := make(chan error, 1)
+
+	l.mutex.Lock()
+	previous := l.stopKeepAlive
+	l.stopKeepAlive = func() {
+		cancel()
+		<-done
+	}
+	l.mutex.Unlock()
+
+	if previous != nil {
+		previous()
+	}
+
+	go func() {
+		defer close(done)
+		defer close(lost)
+
+		ticker := newTicker(l.clock, interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				started := l.clock.Now()
+				err := l.RefreshLock(ctx)
+				if err == nil {
+					if onRefresh != nil {
+						onRefresh(started)
+					}
+					continue
+				}
+				if errors.Is(err, ErrLockAbandoned) {
+					if onLost != nil {
+						onLost()
+					}
+					lost <- err
+					return
+				}
+				if ctx.Err() != nil {
+					// Stopped while refreshing, so the failure is expected
+					return
+				}
+				l.logger(ctx).Error(err, "Failed to refresh lock", "path", l.path)
+			}
+		}
+	}()
+
+	return lost
+}
+
+func (l *Lock) stopRefreshing() {
+	l.mutex.Lock()
+	stop := l.stopKeepAlive
+	l.stopKeepAlive = nil
+	l.mutex.Unlock()
+
+	if stop != nil {
+		stop()
+	}
+}
+
+// defaultRefreshInterval returns the configured refresh interval, otherwise it leaves enough time within the TTL for the
+// whole refresh failure budget to be used up before the lock expires.
+func (l *Lock) defaultRefreshInterval() time.Duration {
+	if l.refreshInterval > 0 {
+		return l.refreshInterval
+	}
+
+	return l.ttl / time.Duration(l.maxRefreshFailures+2)
+}
+
 // RefreshLock will update the information on the lock to ensure that the client still owns it. If ErrLockAbandoned is
 // returned, then the client should assume the lock has been lost and stop immediately.
 func (l *Lock) RefreshLock(ctx context.Context) error {
@@ -107,26 +288,23 @@ func (l *Lock) RefreshLock(ctx context.Context) error {
 		return nil
 	}
 
-	if l.refreshFailures > maxRefreshFailures {
+	if l.refreshFailures > l.maxRefreshFailures {
 		return ErrLockAbandoned
 	}
 
 	l.logger(ctx).Info("Refreshing lock", "path", l.path)
 
-	attrs, err := l.bucket.Object(l.path).
-		If(storage.Conditions{
-			GenerationMatch:     l.latestGeneration,
-			MetagenerationMatch: l.latestMetadataGeneration}).
-		Update(ctx, storage.ObjectAttrsToUpdate{Metadata: l.metadata()})
+	attrs, err := l.backend.Update(ctx, l.path, Conditions{
+		GenerationMatch:     l.latestGeneration,
+		MetagenerationMatch: l.latestMetadataGeneration,
+	}, l.metadata())
 	if err != nil {
-		var gErr *googleapi.Error
-		if errors.Is(err, storage.ErrObjectNotExist) ||
-			(errors.As(err, &gErr) && gErr.Code == http.StatusPreconditionFailed) {
+		if errors.Is(err, ErrNotExist) || errors.Is(err, ErrPreconditionFailed) {
 			return ErrLockAbandoned
 		}
 		l.refreshFailures++
 
-		if l.refreshFailures > maxRefreshFailures {
+		if l.refreshFailures > l.maxRefreshFailures {
 			return ErrLockAbandoned
 		}
 
@@ -138,46 +316,134 @@ func (l *Lock) RefreshLock(ctx context.Context) error {
 	return nil
 }
 
-func (l *Lock) deleteLockIfStale(ctx context.Context) error {
-	attrs, err := l.bucket.Object(l.path).Attrs(ctx)
+// FencingToken returns the generation of the lock object while the lock is held, or zero if it is not. The generation
+// increases every time the lock object is created, so downstream systems can use it to reject writes from a holder
+// whose lock has since expired and been taken by someone else.
+func (l *Lock) FencingToken() int64 {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if !l.refreshMetadata {
+		return 0
+	}
+
+	return l.latestGeneration
+}
+
+// owner returns the identity of the current holder of the lock, or an empty string if the lock isn't held.
+func (l *Lock) owner(ctx context.Context) (string, error) {
+	attrs, err := l.backend.Attrs(ctx, l.path)
 	if err != nil {
-		return err
+		if errors.Is(err, ErrNotExist) {
+			return "", nil
+		}
+		return "", err
 	}
 
-	if attrs.Metadata[ownerMetadata] == l.identity {
-		if err := l.deleteLock(ctx, &attrs.Generation, &attrs.Metageneration, false); err != nil {
-			return err
+	return attrs.Metadata[ownerMetadata], nil
+}
+
+// resumeLock adopts the existing lock, which has already been read, if it's owned by this identity, and was either
+// handed off to it or left behind by it when WithResume is used, so that it can carry on being refreshed. Whether the
+// lock was adopted is returned.
+func (l *Lock) resumeLock(ctx context.Context, attrs *ObjectAttrs) (bool, error) {
+	if attrs.Metadata[ownerMetadata] != l.identity {
+		return false, nil
+	}
+	// The key is cleared rather than removed on claiming the lock, as some backends keep keys missing from an update
+	from := attrs.Metadata[handoffMetadata]
+	handedOff := from != ""
+	if !handedOff && !l.resume {
+		return false, nil
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	metadata := l.metadata()
+	if _, ok := attrs.Metadata[handoffMetadata]; ok {
+		metadata[handoffMetadata] = ""
+	}
+
+	// Refresh the lock straight away, to make sure nobody else has removed it in the meantime
+	updated, err := l.backend.Update(ctx, l.path, Conditions{
+		GenerationMatch:     attrs.Generation,
+		MetagenerationMatch: attrs.Metageneration,
+	}, metadata)
+	if err != nil {
+		if errors.Is(err, ErrNotExist) || errors.Is(err, ErrPreconditionFailed) {
+			return false, nil
 		}
+		return false, err
+	}
+
+	if handedOff {
+		l.logger(ctx).Info("Claimed lock handed off", "path", l.path, "from", from)
+	} else {
+		l.logger(ctx).Info("Resumed lock", "path", l.path, "generation", updated.Generation)
+	}
+	l.refreshMetadata = true
+	l.refreshFailures = 0
+	l.latestGeneration = updated.Generation
+	l.latestMetadataGeneration = updated.Metageneration
+	return true, nil
+}
+
+// resumeOrDeleteStale reads the existing lock once for both resumeLock and deleteStaleLock, returning whether it was
+// resumed, otherwise its holder unless it was removed. The lock having been removed in the meantime isn't an error.
+func (l *Lock) resumeOrDeleteStale(ctx context.Context) (bool, *Holder, error) {
+	attrs, err := l.backend.Attrs(ctx, l.path)
+	if errors.Is(err, ErrNotExist) {
+		return false, nil, nil
+	} else if err != nil {
+		return false, nil, err
+	}
+
+	if resumed, err := l.resumeLock(ctx, attrs); err != nil || resumed {
+		return resumed, nil, err
+	}
+
+	holder, err := l.deleteStaleLock(ctx, attrs)
+	return false, holder, err
+}
+
+// deleteLockIfStale removes the lock if it was left behind by this identity or has expired, otherwise the current
+// holder is returned.
+func (l *Lock) deleteLockIfStale(ctx context.Context) (*Holder, error) {
+	attrs, err := l.backend.Attrs(ctx, l.path)
+	if err != nil {
+		return nil, err
 	}
 
-	expires, err := time.Parse(time.RFC3339Nano, attrs.Metadata[expiresAtMetadata])
-	if err != nil || time.Now().After(expires) {
+	return l.deleteStaleLock(ctx, attrs)
+}
+
+// deleteStaleLock does the same as deleteLockIfStale with the lock which has already been read.
+func (l *Lock) deleteStaleLock(ctx context.Context, attrs *ObjectAttrs) (*Holder, error) {
+	if attrs.Metadata[ownerMetadata] == l.identity {
+		return nil, l.deleteLock(ctx, &attrs.Generation, &attrs.Metageneration, false)
+	}
+
+	if stale, err := l.observed.expired(l.path, attrs, l.clock.Now(), l.maxClockSkew); stale {
 		values := []any{"path", l.path}
 		if err != nil {
 			values = append(values, "err", err)
 		}
 		l.logger(ctx).Info("Lock expired", values...)
-		return l.deleteLock(ctx, &attrs.Generation, &attrs.Metageneration, false)
+		return nil, l.deleteLock(ctx, &attrs.Generation, &attrs.Metageneration, false)
 	}
 
-	return nil
+	return holderOf(attrs), nil
 }
 
 func (l *Lock) createLock(ctx context.Context) error {
 	l.mutex.Lock()
 	defer l.mutex.Unlock()
 
-	o := l.bucket.Object(l.path).If(storage.Conditions{DoesNotExist: true})
-
-	w := o.NewWriter(ctx)
-	w.CacheControl = "no-store"
-	w.Metadata = l.metadata()
-
-	if err := w.Close(); err != nil {
-		return err
-	}
-
-	attrs, err := l.bucket.Object(l.path).Attrs(ctx)
+	attrs, err := l.backend.Create(ctx, l.path, ObjectAttrs{
+		CacheControl: l.cacheControl,
+		Metadata:     l.metadata(),
+	})
 	if err != nil {
 		return err
 	}
@@ -192,36 +458,46 @@ func (l *Lock) deleteLock(ctx context.Context, generation, metageneration *int64
 	l.mutex.Lock()
 	defer l.mutex.Unlock()
 
+	g := l.latestGeneration
+	if generation != nil {
+		g = *generation
+	}
+	m := l.latestMetadataGeneration
+	if metageneration != nil {
+		m = *metageneration
+	}
+
 	if confirmOwner {
 		// Check we still own the lock, on the off chance that the metageneration of the new lock matches what we think
 		// the old one is at.
-		attrs, err := l.bucket.Object(l.path).Attrs(ctx)
+		attrs, err := l.backend.Attrs(ctx, l.path)
 		if err != nil {
-			if errors.Is(err, storage.ErrObjectNotExist) {
+			if errors.Is(err, ErrNotExist) {
 				return nil
 			}
 			return err
 		}
 
 		if attrs.Metadata[ownerMetadata] != l.identity {
-			return errLockOwnedBySomeoneElse
+			return ErrNotOwner
+		}
+
+		if g == 0 && m == 0 {
+			// The lock was never acquired by this client, so only remove the lock which was just checked, rather than
+			// any lock which has replaced it since
+			g, m = attrs.Generation, attrs.Metageneration
 		}
 	}
 
 	l.refreshMetadata = false
 
-	g := l.latestGeneration
-	if generation != nil {
-		g = *generation
-	}
-	m := l.latestMetadataGeneration
-	if metageneration != nil {
-		m = *metageneration
+	if g == 0 && m == 0 {
+		// Zero conditions are ignored by the Backend, which would remove the lock whoever holds it
+		return errors.New("unable to delete lock without knowing its generation")
 	}
 
-	if err := l.bucket.Object(l.path).If(storage.Conditions{GenerationMatch: g, MetagenerationMatch: m}).Delete(ctx); err != nil {
-		var gErr *googleapi.Error
-		if errors.Is(err, storage.ErrObjectNotExist) || (errors.As(err, &gErr) && gErr.Code == http.StatusPreconditionFailed) {
+	if err := l.backend.Delete(ctx, l.path, Conditions{GenerationMatch: g, MetagenerationMatch: m}); err != nil {
+		if errors.Is(err, ErrNotExist) || errors.Is(err, ErrPreconditionFailed) {
 			// TODO what could a caller do if they get StatusPreconditionFailed?
 			return nil
 		}
@@ -232,10 +508,104 @@ func (l *Lock) deleteLock(ctx context.Context, generation, metageneration *int64
 }
 
 func (l *Lock) metadata() map[string]string {
-	ttl := time.Now().UTC().Add(l.ttl).Format(time.RFC3339Nano)
+	return l.metadataFor(l.identity, l.ttl)
+}
 
-	return map[string]string{
-		expiresAtMetadata: ttl,
-		ownerMetadata:     l.identity,
+func (l *Lock) metadataFor(owner string, ttl time.Duration) map[string]string {
+	metadata := maps.Clone(l.extraMetadata)
+	if metadata == nil {
+		metadata = map[string]string{}
 	}
+	metadata[expiresAtMetadata] = l.clock.Now().UTC().Add(ttl).Format(time.RFC3339Nano)
+	metadata[ownerMetadata] = owner
+	metadata[ttlMetadata] = ttl.String()
+
+	return metadata
+}
+
+// holderOf describes the holder of the lock object. The expiry is left unset if it can't be worked out.
+func holderOf(attrs *ObjectAttrs) *Holder {
+	expires, _ := expiresAt(attrs)
+	return &Holder{
+		Owner:     attrs.Metadata[ownerMetadata],
+		ExpiresAt: expires,
+	}
+}
+
+// isStale reports whether the lock object has expired by the time given, allowing for the maximum clock skew, or its
+// expiry can't be worked out, in which case the reason is returned. The time given is from the local clock, so without
+// a maximum clock skew a client whose clock runs ahead treats the lock as expired early, unless observations.expired
+// is used as well.
+func isStale(attrs *ObjectAttrs, now time.Time, maxClockSkew time.Duration) (bool, error) {
+	expires, err := expiresAt(attrs)
+	if err != nil {
+		return true, err
+	}
+
+	return now.Add(-maxClockSkew).After(expires), nil
+}
+
+// observations record when each version of other clients' lock objects was first seen, by the local clock.
+type observations struct {
+	mutex sync.Mutex
+	seen  map[string]observation
+}
+
+type observation struct {
+	generation     int64
+	metageneration int64
+	at             time.Time
+}
+
+// expired reports whether the lock object is stale, as isStale does, and has also gone unchanged for its TTL since it
+// was first seen. Only the local clock is used to tell how long it has gone unchanged, so a lock can't be taken early
+// however much the clocks of its holder, the Backend and this client differ, as long as they run at the same rate.
+func (o *observations) expired(path string, attrs *ObjectAttrs, now time.Time, maxClockSkew time.Duration) (bool, error) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	seen, ok := o.seen[path]
+	if !ok || seen.generation != attrs.Generation || seen.metageneration != attrs.Metageneration {
+		if o.seen == nil {
+			o.seen = map[string]observation{}
+		}
+		seen = observation{generation: attrs.Generation, metageneration: attrs.Metageneration, at: now}
+		o.seen[path] = seen
+	}
+
+	stale, err := isStale(attrs, now, maxClockSkew)
+	if err != nil || !stale {
+		return stale, err
+	}
+	if ttl, err := time.ParseDuration(attrs.Metadata[ttlMetadata]); err == nil && now.Sub(seen.at) < ttl {
+		return false, nil
+	}
+
+	delete(o.seen, path)
+	return true, nil
+}
+
+// retain forgets the lock objects under the prefix which aren't in paths, as they've since been removed.
+func (o *observations) retain(prefix string, paths []string) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	for path := range o.seen {
+		if strings.HasPrefix(path, prefix) && !slices.Contains(paths, path) {
+			delete(o.seen, path)
+		}
+	}
+}
+
+// expiresAt works out when the lock object expires. Where the Backend reports when the object was last updated, the
+// holder's TTL is added to that so that expiry is judged by the Backend's clock rather than the holder's. Otherwise, the
+// expiry time written by the holder is used.
+func expiresAt(attrs *ObjectAttrs) (time.Time, error) {
+	if !attrs.Updated.IsZero() {
+		if ttl, err := time.ParseDuration(attrs.Metadata[ttlMetadata]); err == nil {
+			return attrs.Updated.Add(ttl), nil
+		}
+	}
+
+	return time.Parse(time.RFC3339Nano, attrs.Metadata[expiresAtMetadata])
 }
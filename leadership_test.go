@@ -2,23 +2,29 @@ package lock
 
 import (
 	"context"
+	"net/http"
 	"testing"
 	"time"
 
 	"cloud.google.com/go/storage"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/thg-ice/distributed-lock/mock_clock"
 	"github.com/thg-ice/distributed-lock/mock_gcs"
 )
 
 func TestLock_Lock(t *testing.T) {
 	tests := []struct {
-		name                string
-		skipInitialObject   bool
-		initialObjectOwner  string
-		initialObjectExpiry time.Duration
-		expectedErr         string
-		expectedObj         *storage.ObjectAttrs
+		name                 string
+		skipInitialObject    bool
+		initialObjectOwner   string
+		initialObjectExpiry  time.Duration
+		initialObjectTTL     string
+		initialObjectUpdated time.Duration
+		initialObjectSeen    time.Duration
+		maxClockSkew         time.Duration
+		expectedErr          string
+		expectedObj          *storage.ObjectAttrs
 	}{
 		{
 			name:              "creates-lock-when-not-present",
@@ -29,6 +35,7 @@ func TestLock_Lock(t *testing.T) {
 				CacheControl: "no-store",
 				Metadata: map[string]string{
 					ownerMetadata: "id",
+					ttlMetadata:   "3m0s",
 				},
 				Generation:     1,
 				Metageneration: 1,
@@ -38,7 +45,7 @@ func TestLock_Lock(t *testing.T) {
 			name:                "cannot-lock-if-already-present",
 			initialObjectOwner:  "someone-else",
 			initialObjectExpiry: 3 * time.Minute,
-			expectedErr:         "got HTTP response code 412 with body: createObject already has that object",
+			expectedErr:         "lock held by someone-else",
 			expectedObj: &storage.ObjectAttrs{
 				Bucket:       "b",
 				Name:         "testing",
@@ -60,8 +67,9 @@ func TestLock_Lock(t *testing.T) {
 				CacheControl: "no-store",
 				Metadata: map[string]string{
 					ownerMetadata: "id",
+					ttlMetadata:   "3m0s",
 				},
-				Generation:     1,
+				Generation:     2,
 				Metageneration: 1,
 			},
 		},
@@ -75,28 +83,111 @@ func TestLock_Lock(t *testing.T) {
 				CacheControl: "no-store",
 				Metadata: map[string]string{
 					ownerMetadata: "id",
+					ttlMetadata:   "3m0s",
+				},
+				Generation:     2,
+				Metageneration: 1,
+			},
+		},
+		{
+			name:                 "keeps-lock-if-unexpired-by-server-time",
+			initialObjectOwner:   "someone-else",
+			initialObjectExpiry:  -4 * time.Minute,
+			initialObjectTTL:     "3m0s",
+			initialObjectUpdated: -1 * time.Minute,
+			expectedErr:          "lock held by someone-else",
+			expectedObj: &storage.ObjectAttrs{
+				Bucket:       "b",
+				Name:         "testing",
+				CacheControl: "no-store",
+				Metadata: map[string]string{
+					ownerMetadata: "someone-else",
+					ttlMetadata:   "3m0s",
+				},
+				Generation:     1,
+				Metageneration: 6,
+			},
+		},
+		{
+			name:                 "keeps-lock-expired-by-server-time-until-seen-unchanged-for-ttl",
+			initialObjectOwner:   "someone-else",
+			initialObjectExpiry:  10 * time.Minute,
+			initialObjectTTL:     "3m0s",
+			initialObjectUpdated: -4 * time.Minute,
+			initialObjectSeen:    -2 * time.Minute,
+			expectedErr:          "lock held by someone-else",
+			expectedObj: &storage.ObjectAttrs{
+				Bucket:       "b",
+				Name:         "testing",
+				CacheControl: "no-store",
+				Metadata: map[string]string{
+					ownerMetadata: "someone-else",
+					ttlMetadata:   "3m0s",
 				},
 				Generation:     1,
+				Metageneration: 6,
+			},
+		},
+		{
+			name:                 "removes-lock-if-expired-by-server-time",
+			initialObjectOwner:   "someone-else",
+			initialObjectExpiry:  10 * time.Minute,
+			initialObjectTTL:     "3m0s",
+			initialObjectUpdated: -4 * time.Minute,
+			initialObjectSeen:    -4 * time.Minute,
+			expectedObj: &storage.ObjectAttrs{
+				Bucket:       "b",
+				Name:         "testing",
+				CacheControl: "no-store",
+				Metadata: map[string]string{
+					ownerMetadata: "id",
+					ttlMetadata:   "3m0s",
+				},
+				Generation:     2,
 				Metageneration: 1,
 			},
 		},
+		{
+			name:                "keeps-lock-if-expired-within-clock-skew",
+			initialObjectOwner:  "someone-else",
+			initialObjectExpiry: -1 * time.Minute,
+			maxClockSkew:        2 * time.Minute,
+			expectedErr:         "lock held by someone-else",
+			expectedObj: &storage.ObjectAttrs{
+				Bucket:       "b",
+				Name:         "testing",
+				CacheControl: "no-store",
+				Metadata: map[string]string{
+					ownerMetadata: "someone-else",
+				},
+				Generation:     1,
+				Metageneration: 6,
+			},
+		},
 	}
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
+			initialExpiresAt := time.Now().UTC().Add(test.initialObjectExpiry).Format(time.RFC3339Nano)
+
 			mock := mock_gcs.NewServer("b", mock_gcs.WithFailOnObjectExistence())
 			if !test.skipInitialObject {
-				mock.Add("testing", storage.ObjectAttrs{
+				attrs := storage.ObjectAttrs{
 					Bucket: "b",
 					Name:   "testing",
 					Metadata: map[string]string{
 						ownerMetadata:     test.initialObjectOwner,
-						expiresAtMetadata: time.Now().UTC().Add(test.initialObjectExpiry).Format(time.RFC3339Nano),
+						expiresAtMetadata: initialExpiresAt,
 					},
 					Generation:     1,
 					Metageneration: 6,
 					CacheControl:   "no-store",
-				})
+				}
+				if test.initialObjectTTL != "" {
+					attrs.Metadata[ttlMetadata] = test.initialObjectTTL
+					attrs.Updated = time.Now().Add(test.initialObjectUpdated)
+				}
+				mock.Add("testing", attrs)
 			}
 			t.Cleanup(mock.Close)
 
@@ -110,26 +201,35 @@ func TestLock_Lock(t *testing.T) {
 
 			subject := NewLock(client.Bucket("b"), "id", "testing", ttl, func(context.Context) Logger {
 				return loggerToTestingT{t}
-			})
+			}, WithMaxClockSkew(test.maxClockSkew))
+			if test.initialObjectSeen != 0 {
+				subject.observed.seen = map[string]observation{
+					"testing": {generation: 1, metageneration: 6, at: time.Now().Add(test.initialObjectSeen)},
+				}
+			}
 
 			err = subject.Lock(ctx, 500*time.Millisecond)
 
-			if test.expectedErr == "" {
-				assert.NoError(t, err)
-			} else {
-				assert.ErrorContains(t, err, test.expectedErr)
-			}
-
 			actualObj := mock.Get("testing")
 			require.NotNil(t, actualObj)
 
 			assert.Contains(t, actualObj.Metadata, expiresAtMetadata)
-			expiresAt, err := time.Parse(time.RFC3339Nano, actualObj.Metadata[expiresAtMetadata])
-			require.NoError(t, err)
+			if test.expectedErr == "" {
+				assert.NoError(t, err)
 
-			assert.WithinDuration(t, expiresAt, time.Now().UTC().Add(ttl), 1*time.Minute)
+				expiresAt, err := time.Parse(time.RFC3339Nano, actualObj.Metadata[expiresAtMetadata])
+				require.NoError(t, err)
+
+				assert.WithinDuration(t, expiresAt, time.Now().UTC().Add(ttl), 1*time.Minute)
+				assert.WithinDuration(t, time.Now(), actualObj.Updated, 1*time.Minute)
+			} else {
+				assert.ErrorContains(t, err, test.expectedErr)
+				assert.Equal(t, initialExpiresAt, actualObj.Metadata[expiresAtMetadata])
+			}
 
 			delete(actualObj.Metadata, expiresAtMetadata)
+			actualObj.Created = time.Time{}
+			actualObj.Updated = time.Time{}
 
 			assert.Equal(t, test.expectedObj, actualObj)
 		})
@@ -292,6 +392,112 @@ func TestLock_RefreshLock(t *testing.T) {
 	}
 }
 
+func TestLock_RefreshLock_FailureBudget(t *testing.T) {
+	mock := mock_gcs.NewServer("b", mock_gcs.WithFailOnObjectExistence())
+	t.Cleanup(mock.Close)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	t.Cleanup(cancel)
+
+	client, err := mock.Client(ctx)
+	require.NoError(t, err)
+
+	client.SetRetry(storage.WithMaxAttempts(1))
+
+	subject := NewLock(client.Bucket("b"), "id", "testing", time.Minute, func(context.Context) Logger {
+		return loggerToTestingT{t}
+	}, WithMaxRefreshFailures(2))
+	require.NoError(t, subject.Lock(ctx, 500*time.Millisecond))
+
+	// Failures within the budget are reported, but a success resets it
+	mock.SetFaults(mock_gcs.Fault{Method: http.MethodPatch, Status: http.StatusServiceUnavailable, Times: 2})
+	for range 2 {
+		err := subject.RefreshLock(ctx)
+		require.Error(t, err)
+		assert.NotErrorIs(t, err, ErrLockAbandoned)
+	}
+	require.NoError(t, subject.RefreshLock(ctx))
+
+	// Exceeding the budget abandons the lock, even once the backend recovers
+	mock.SetFaults(mock_gcs.Fault{Method: http.MethodPatch, Status: http.StatusInternalServerError, Times: 3})
+	for range 2 {
+		assert.NotErrorIs(t, subject.RefreshLock(ctx), ErrLockAbandoned)
+	}
+	assert.ErrorIs(t, subject.RefreshLock(ctx), ErrLockAbandoned)
+	assert.ErrorIs(t, subject.RefreshLock(ctx), ErrLockAbandoned)
+
+	// Losing a race to update the lock abandons it straight away
+	mock.SetFaults()
+	other := NewLock(client.Bucket("b"), "id", "testing", time.Minute, func(context.Context) Logger {
+		return loggerToTestingT{t}
+	})
+	require.NoError(t, other.Lock(ctx, 500*time.Millisecond))
+	mock.SetFaults(mock_gcs.Fault{Method: http.MethodPatch, Status: http.StatusPreconditionFailed})
+	assert.ErrorIs(t, other.RefreshLock(ctx), ErrLockAbandoned)
+}
+
+func TestLock_Lock_RetriesFaults(t *testing.T) {
+	mock := mock_gcs.NewServer("b", mock_gcs.WithFailOnObjectExistence(), mock_gcs.WithFaults(
+		mock_gcs.Fault{Method: http.MethodPost, Object: "testing", Status: http.StatusTooManyRequests, Times: 1},
+		mock_gcs.Fault{Method: http.MethodPost, Object: "testing", Status: http.StatusServiceUnavailable, After: 1, Times: 1},
+		mock_gcs.Fault{Method: http.MethodPost, Object: "testing", Drop: true, After: 2, Times: 1},
+		mock_gcs.Fault{Method: http.MethodPost, Object: "testing", Status: http.StatusInternalServerError, After: 3, Times: 1},
+	))
+	t.Cleanup(mock.Close)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	t.Cleanup(cancel)
+
+	client, err := mock.Client(ctx)
+	require.NoError(t, err)
+
+	client.SetRetry(storage.WithMaxAttempts(1))
+
+	subject := NewLock(client.Bucket("b"), "id", "testing", time.Minute, func(context.Context) Logger {
+		return loggerToTestingT{t}
+	}, WithRetryInterval(5*time.Millisecond))
+	require.NoError(t, subject.Lock(ctx, 10*time.Second))
+	assert.Equal(t, "id", mock.Get("testing").Metadata[ownerMetadata])
+
+	// A backend which never responds is given up on once the timeout is reached
+	require.NoError(t, subject.Unlock(ctx))
+	mock.SetFaults(mock_gcs.Fault{Method: http.MethodPost, Hang: true})
+	assert.ErrorIs(t, subject.Lock(ctx, 100*time.Millisecond), ErrTimeout)
+}
+
+func TestLock_Lock_TimesOutDuringAttempt(t *testing.T) {
+	mock := mock_gcs.NewServer("b", mock_gcs.WithFailOnObjectExistence())
+	t.Cleanup(mock.Close)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	t.Cleanup(cancel)
+
+	client, err := mock.Client(ctx)
+	require.NoError(t, err)
+
+	client.SetRetry(storage.WithMaxAttempts(1))
+
+	logger := func(context.Context) Logger {
+		return loggerToTestingT{t}
+	}
+	first := NewLock(client.Bucket("b"), "first", "testing", time.Minute, logger)
+	require.NoError(t, first.Lock(ctx, time.Second))
+
+	// The first attempt finds the lock held, and the next is still waiting for a response when the timeout is reached
+	mock.SetFaults(mock_gcs.Fault{Method: http.MethodPost, Object: "testing", After: 1, Hang: true})
+	second := NewLock(client.Bucket("b"), "second", "testing", time.Minute, logger, WithRetryInterval(5*time.Millisecond))
+	err = second.Lock(ctx, 200*time.Millisecond)
+
+	var timeoutErr *TimeoutError
+	require.ErrorAs(t, err, &timeoutErr)
+	assert.Equal(t, 2, timeoutErr.Attempts)
+	assert.ErrorIs(t, err, ErrLockHeld)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	var heldErr *LockHeldError
+	require.ErrorAs(t, err, &heldErr)
+	assert.Equal(t, "first", heldErr.Holder.Owner)
+}
+
 func TestLock_Unlock(t *testing.T) {
 	tests := []struct {
 		name                  string
@@ -330,7 +536,7 @@ func TestLock_Unlock(t *testing.T) {
 			objectOwner:           "someone-else",
 			objectMetageneration:  2,
 			initialMetageneration: 2,
-			expectedErr:           errLockOwnedBySomeoneElse,
+			expectedErr:           ErrNotOwner,
 			expectObjectToRemain:  true,
 		},
 	}
@@ -377,6 +583,351 @@ func TestLock_Unlock(t *testing.T) {
 	}
 }
 
+func TestLock_Unlock_NeverAcquired(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	t.Cleanup(cancel)
+
+	backend := &replacingBackend{memoryBackend: newMemoryBackend()}
+	_, err := backend.Create(ctx, "testing", ObjectAttrs{Metadata: map[string]string{ownerMetadata: "id"}})
+	require.NoError(t, err)
+
+	subject, err := New(backend, "id", "testing")
+	require.NoError(t, err)
+
+	// A lock left behind by this identity is removed
+	require.NoError(t, subject.Unlock(ctx))
+	assert.Empty(t, backend.objects)
+
+	// But not a lock which replaces it after it's been checked
+	_, err = backend.Create(ctx, "testing", ObjectAttrs{Metadata: map[string]string{ownerMetadata: "id"}})
+	require.NoError(t, err)
+	backend.afterAttrs = func() {
+		backend.objects["testing"].Generation++
+	}
+	require.NoError(t, subject.Unlock(ctx))
+	assert.Contains(t, backend.objects, "testing")
+}
+
+// replacingBackend runs afterAttrs once after the next call to Attrs, to simulate the object changing straight after
+// it's been read.
+type replacingBackend struct {
+	*memoryBackend
+	afterAttrs func()
+}
+
+func (r *replacingBackend) Attrs(ctx context.Context, path string) (*ObjectAttrs, error) {
+	attrs, err := r.memoryBackend.Attrs(ctx, path)
+	if r.afterAttrs != nil {
+		r.memoryBackend.mutex.Lock()
+		r.afterAttrs()
+		r.memoryBackend.mutex.Unlock()
+		r.afterAttrs = nil
+	}
+	return attrs, err
+}
+
+func TestLock_KeepAlive(t *testing.T) {
+	tests := []struct {
+		name        string
+		removeLock  bool
+		expectedErr error
+	}{
+		{
+			name:        "refreshes-until-unlocked",
+			removeLock:  false,
+			expectedErr: nil,
+		},
+		{
+			name:        "reports-lost-lock",
+			removeLock:  true,
+			expectedErr: ErrLockAbandoned,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mock := mock_gcs.NewServer("b", mock_gcs.WithFailOnObjectExistence())
+			t.Cleanup(mock.Close)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+			t.Cleanup(cancel)
+
+			client, err := mock.Client(ctx)
+			require.NoError(t, err)
+
+			subject := NewLock(client.Bucket("b"), "id", "testing", 3*time.Minute, func(context.Context) Logger {
+				return loggerToTestingT{t}
+			})
+			require.NoError(t, subject.Lock(ctx, 500*time.Millisecond))
+
+			lost := subject.KeepAlive(ctx, 10*time.Millisecond)
+
+			require.Eventually(t, func() bool {
+				return mock.Get("testing").Metageneration > 2
+			}, 10*time.Second, 10*time.Millisecond)
+
+			if test.removeLock {
+				mock.RemoveAll()
+				select {
+				case err := <-lost:
+					assert.ErrorIs(t, err, test.expectedErr)
+				case <-ctx.Done():
+					require.Fail(t, "lost lock was never reported")
+				}
+			}
+
+			require.NoError(t, subject.Unlock(ctx))
+
+			_, open := <-lost
+			assert.False(t, open, "refresher should stop when unlocked")
+			assert.Nil(t, mock.Get("testing"))
+
+			// Every write to the lock must be conditional on it being unchanged
+			assert.NoError(t, mock.CheckPreconditions(http.MethodPost, "testing", "ifGenerationMatch"))
+			assert.NoError(t, mock.CheckPreconditions(http.MethodPatch, "testing", "ifGenerationMatch", "ifMetagenerationMatch"))
+			if !test.removeLock {
+				assert.NoError(t, mock.CheckPreconditions(http.MethodDelete, "testing", "ifGenerationMatch", "ifMetagenerationMatch"))
+			}
+		})
+	}
+}
+
+func TestLock_LockContext(t *testing.T) {
+	tests := []struct {
+		name          string
+		removeLock    bool
+		expectedCause error
+	}{
+		{
+			name:          "cancelled-on-release",
+			removeLock:    false,
+			expectedCause: context.Canceled,
+		},
+		{
+			name:          "cancelled-when-lock-lost",
+			removeLock:    true,
+			expectedCause: ErrLockAbandoned,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mock := mock_gcs.NewServer("b", mock_gcs.WithFailOnObjectExistence())
+			t.Cleanup(mock.Close)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+			t.Cleanup(cancel)
+
+			client, err := mock.Client(ctx)
+			require.NoError(t, err)
+
+			subject := NewLock(client.Bucket("b"), "id", "testing", time.Second, func(context.Context) Logger {
+				return loggerToTestingT{t}
+			})
+
+			lockCtx, release, err := subject.LockContext(ctx, 500*time.Millisecond)
+			require.NoError(t, err)
+			require.NoError(t, lockCtx.Err())
+
+			if test.removeLock {
+				mock.RemoveAll()
+				select {
+				case <-lockCtx.Done():
+				case <-ctx.Done():
+					require.Fail(t, "context was not cancelled when the lock was lost")
+				}
+			}
+
+			require.NoError(t, release())
+
+			assert.ErrorIs(t, context.Cause(lockCtx), test.expectedCause)
+			assert.Nil(t, mock.Get("testing"))
+		})
+	}
+}
+
+func TestLock_TryLock(t *testing.T) {
+	tests := []struct {
+		name                string
+		skipInitialObject   bool
+		initialObjectOwner  string
+		initialObjectExpiry time.Duration
+		expectedAcquired    bool
+		expectedOwner       string
+	}{
+		{
+			name:              "acquires-lock-when-not-present",
+			skipInitialObject: true,
+			expectedAcquired:  true,
+		},
+		{
+			name:                "returns-holder-if-already-present",
+			initialObjectOwner:  "someone-else",
+			initialObjectExpiry: 3 * time.Minute,
+			expectedOwner:       "someone-else",
+		},
+		{
+			name:                "acquires-lock-if-expired",
+			initialObjectOwner:  "someone-else",
+			initialObjectExpiry: -4 * time.Minute,
+			expectedAcquired:    true,
+		},
+		{
+			name:                "acquires-lock-if-owned-by-self",
+			initialObjectOwner:  "id",
+			initialObjectExpiry: 3 * time.Minute,
+			expectedAcquired:    true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mock := mock_gcs.NewServer("b", mock_gcs.WithFailOnObjectExistence())
+			t.Cleanup(mock.Close)
+
+			initialExpiresAt := time.Now().UTC().Add(test.initialObjectExpiry).Truncate(time.Second)
+			if !test.skipInitialObject {
+				mock.Add("testing", storage.ObjectAttrs{
+					Bucket: "b",
+					Name:   "testing",
+					Metadata: map[string]string{
+						ownerMetadata:     test.initialObjectOwner,
+						expiresAtMetadata: initialExpiresAt.Format(time.RFC3339Nano),
+					},
+					Generation:     1,
+					Metageneration: 6,
+				})
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+			t.Cleanup(cancel)
+
+			client, err := mock.Client(ctx)
+			require.NoError(t, err)
+
+			subject := NewLock(client.Bucket("b"), "id", "testing", 3*time.Minute, func(context.Context) Logger {
+				return loggerToTestingT{t}
+			})
+
+			acquired, holder, err := subject.TryLock(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedAcquired, acquired)
+
+			if test.expectedAcquired {
+				assert.Nil(t, holder)
+				assert.Equal(t, "id", mock.Get("testing").Metadata[ownerMetadata])
+			} else {
+				assert.Equal(t, &Holder{Owner: test.expectedOwner, ExpiresAt: initialExpiresAt}, holder)
+				assert.Len(t, mock.RequestsFor(http.MethodGet, "testing"), 1, "the lock should only be read once")
+			}
+		})
+	}
+}
+
+func TestLock_Resume(t *testing.T) {
+	tests := []struct {
+		name               string
+		resume             bool
+		initialOwner       string
+		expectedErr        error
+		expectedGeneration int64
+	}{
+		{
+			name:               "resumes-own-lock",
+			resume:             true,
+			initialOwner:       "id",
+			expectedGeneration: 1,
+		},
+		{
+			name:               "replaces-own-lock-without-resume",
+			initialOwner:       "id",
+			expectedGeneration: 2,
+		},
+		{
+			name:         "does-not-resume-lock-owned-by-someone-else",
+			resume:       true,
+			initialOwner: "someone-else",
+			expectedErr:  ErrLockHeld,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+			t.Cleanup(cancel)
+
+			backend := newMemoryBackend()
+			_, err := backend.Create(ctx, "testing", ObjectAttrs{Metadata: map[string]string{
+				ownerMetadata: test.initialOwner,
+				ttlMetadata:   "1m0s",
+			}})
+			require.NoError(t, err)
+
+			opts := []Option{WithTTL(time.Minute), WithRetryInterval(5 * time.Millisecond), WithLogger(func(context.Context) Logger {
+				return loggerToTestingT{t}
+			})}
+			if test.resume {
+				opts = append(opts, WithResume())
+			}
+			subject, err := New(backend, "id", "testing", opts...)
+			require.NoError(t, err)
+
+			err = subject.Lock(ctx, 100*time.Millisecond)
+			if test.expectedErr != nil {
+				assert.ErrorIs(t, err, test.expectedErr)
+				assert.Zero(t, subject.FencingToken())
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedGeneration, subject.FencingToken())
+
+			// The lock carries on being refreshed from where it was left
+			require.NoError(t, subject.RefreshLock(ctx))
+			attrs, err := backend.Attrs(ctx, "testing")
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedGeneration, attrs.Generation)
+			assert.Equal(t, "id", attrs.Metadata[ownerMetadata])
+
+			// A restarted client with the same identity resumes with TryLock too
+			restarted, err := New(backend, "id", "testing", opts...)
+			require.NoError(t, err)
+			acquired, _, err := restarted.TryLock(ctx)
+			require.NoError(t, err)
+			assert.True(t, acquired)
+			if test.resume {
+				assert.Equal(t, subject.FencingToken(), restarted.FencingToken())
+			} else {
+				assert.Greater(t, restarted.FencingToken(), subject.FencingToken())
+			}
+		})
+	}
+}
+
+func TestLock_FencingToken(t *testing.T) {
+	mock := mock_gcs.NewServer("b", mock_gcs.WithFailOnObjectExistence())
+	t.Cleanup(mock.Close)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	t.Cleanup(cancel)
+
+	client, err := mock.Client(ctx)
+	require.NoError(t, err)
+
+	subject := NewLock(client.Bucket("b"), "id", "testing", 3*time.Minute, func(context.Context) Logger {
+		return loggerToTestingT{t}
+	})
+	assert.Zero(t, subject.FencingToken())
+
+	require.NoError(t, subject.Lock(ctx, 500*time.Millisecond))
+	assert.Equal(t, mock.Get("testing").Generation, subject.FencingToken())
+
+	require.NoError(t, subject.RefreshLock(ctx))
+	assert.Equal(t, mock.Get("testing").Generation, subject.FencingToken())
+
+	require.NoError(t, subject.Unlock(ctx))
+	assert.Zero(t, subject.FencingToken())
+}
+
 var _ Logger = loggerToTestingT{}
 
 type loggerToTestingT struct {
@@ -396,3 +947,122 @@ func (l loggerToTestingT) Error(err error, msg string, keysAndValues ...any) {
 	}
 	l.Logf("ERROR: %s: %s, %#v", err, msg, keysAndValues)
 }
+
+func TestLock_Clock(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	t.Cleanup(cancel)
+
+	start := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+	clock := mock_clock.NewClock(start)
+
+	mock := mock_gcs.NewServer("b", mock_gcs.WithFailOnObjectExistence(), mock_gcs.WithClock(clock))
+	t.Cleanup(mock.Close)
+
+	client, err := mock.Client(ctx)
+	require.NoError(t, err)
+
+	client.SetRetry(storage.WithMaxAttempts(1))
+
+	newClockLock := func(identity string) *Lock {
+		l, err := New(NewGCSBackend(client.Bucket("b")), identity, "testing", WithTTL(time.Minute), WithClock(clock),
+			WithLogger(func(context.Context) Logger {
+				return loggerToTestingT{t}
+			}))
+		require.NoError(t, err)
+		return l
+	}
+
+	holder := newClockLock("holder")
+	require.NoError(t, holder.Lock(ctx, time.Second))
+	assert.Equal(t, start, mock.Get("testing").Created)
+
+	// The lock is held until the TTL has passed by the clock, since it was first seen
+	other := newClockLock("other")
+	acquired, current, err := other.TryLock(ctx)
+	require.NoError(t, err)
+	assert.False(t, acquired)
+	clock.Advance(time.Minute)
+	acquired, current, err = other.TryLock(ctx)
+	require.NoError(t, err)
+	assert.False(t, acquired)
+	assert.Equal(t, &Holder{Owner: "holder", ExpiresAt: start.Add(time.Minute)}, current)
+
+	// After which it's taken over
+	clock.Advance(time.Millisecond)
+	acquired, _, err = other.TryLock(ctx)
+	require.NoError(t, err)
+	assert.True(t, acquired)
+	assert.ErrorIs(t, holder.RefreshLock(ctx), ErrLockAbandoned)
+
+	// Refreshes are made as the clock reaches each refresh interval
+	lost := other.KeepAlive(ctx, 10*time.Second)
+	require.Eventually(t, func() bool { return clock.Timers() == 1 }, 5*time.Second, time.Millisecond)
+	clock.Advance(10 * time.Second)
+	require.Eventually(t, func() bool { return mock.Get("testing").Metageneration == 2 }, 5*time.Second, time.Millisecond)
+	assert.Equal(t, start.Add(time.Minute+10*time.Second+time.Millisecond), mock.Get("testing").Updated)
+	require.NoError(t, other.Unlock(ctx))
+	for range lost {
+	}
+
+	// A lock which can't be refreshed is lost once its TTL passes
+	lockCtx, release, err := holder.LockContext(ctx, time.Second)
+	require.NoError(t, err)
+	mock.SetFaults(mock_gcs.Fault{Method: http.MethodPatch, Status: http.StatusServiceUnavailable})
+	clock.Advance(time.Minute)
+	select {
+	case <-lockCtx.Done():
+		assert.ErrorIs(t, context.Cause(lockCtx), ErrLockAbandoned)
+	case <-time.After(5 * time.Second):
+		t.Fatal("lock wasn't lost once its TTL had passed")
+	}
+	mock.SetFaults()
+	require.NoError(t, release())
+}
+
+func TestLock_ClockSkew(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	t.Cleanup(cancel)
+
+	start := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+	serverClock := mock_clock.NewClock(start)
+	// The contender's clock is well ahead of the server's, and no maximum clock skew is given
+	contenderClock := mock_clock.NewClock(start.Add(10 * time.Minute))
+
+	mock := mock_gcs.NewServer("b", mock_gcs.WithFailOnObjectExistence(), mock_gcs.WithClock(serverClock))
+	t.Cleanup(mock.Close)
+
+	client, err := mock.Client(ctx)
+	require.NoError(t, err)
+
+	newClockLock := func(identity string, clock Clock) *Lock {
+		l, err := New(NewGCSBackend(client.Bucket("b")), identity, "testing", WithTTL(time.Minute), WithClock(clock),
+			WithLogger(func(context.Context) Logger {
+				return loggerToTestingT{t}
+			}))
+		require.NoError(t, err)
+		return l
+	}
+	holder, contender := newClockLock("holder", serverClock), newClockLock("contender", contenderClock)
+
+	require.NoError(t, holder.Lock(ctx, time.Second))
+
+	// The lock looks long expired to the contender, but it isn't taken while the holder keeps refreshing it
+	for range 3 {
+		acquired, _, err := contender.TryLock(ctx)
+		require.NoError(t, err)
+		assert.False(t, acquired)
+
+		serverClock.Advance(40 * time.Second)
+		contenderClock.Advance(40 * time.Second)
+		require.NoError(t, holder.RefreshLock(ctx))
+	}
+
+	// Once the holder stops, it's taken after going unchanged for the TTL
+	acquired, _, err := contender.TryLock(ctx)
+	require.NoError(t, err)
+	assert.False(t, acquired)
+	contenderClock.Advance(time.Minute + time.Millisecond)
+	acquired, _, err = contender.TryLock(ctx)
+	require.NoError(t, err)
+	assert.True(t, acquired)
+}
@@ -0,0 +1,128 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// LockSet holds locks on several paths at once. The locks are always acquired in the same order, sorted by path, so
+// that clients wanting overlapping sets of paths can't deadlock by each holding a path the other is waiting for. The
+// set is only held when all of its locks are held, and is lost as soon as any of them is lost.
+type LockSet struct {
+	locks []*Lock
+}
+
+// NewLockSet creates a set of locks on the paths in the Backend. Duplicate paths are ignored. The options are applied
+// to the Lock for each path.
+func NewLockSet(backend Backend, identity string, paths []string, opts ...Option) (*LockSet, error) {
+	if len(paths) == 0 {
+		return nil, errors.New("invalid lock set configuration: at least one path must be given")
+	}
+
+	paths = slices.Clone(paths)
+	slices.Sort(paths)
+	paths = slices.Compact(paths)
+
+	s := &LockSet{locks: make([]*Lock, len(paths))}
+	for i, path := range paths {
+		l, err := New(backend, identity, path, opts...)
+		if err != nil {
+			return nil, err
+		}
+		s.locks[i] = l
+	}
+
+	return s, nil
+}
+
+// Lock will attempt to acquire every lock in the set, in order, until the context has timed out. If any of them can't
+// be acquired in time, those already acquired are released again and the error for the lock which couldn't be acquired
+// is returned.
+func (s *LockSet) Lock(ctx context.Context, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	for i, l := range s.locks {
+		deadline, _ := ctx.Deadline()
+		if err := l.Lock(ctx, time.Until(deadline)); err != nil {
+			err = fmt.Errorf("unable to acquire lock on %s: %w", l.path, err)
+			return errors.Join(err, unlockAll(context.WithoutCancel(ctx), s.locks[:i]))
+		}
+	}
+
+	return nil
+}
+
+// Unlock will release every lock in the set, stopping any refreshers started by KeepAlive first.
+func (s *LockSet) Unlock(ctx context.Context) error {
+	return unlockAll(ctx, s.locks)
+}
+
+// unlockAll releases the locks in reverse order, carrying on past any which fail.
+func unlockAll(ctx context.Context, locks []*Lock) error {
+	var errs []error
+	for i := len(locks) - 1; i >= 0; i-- {
+		if err := locks[i].Unlock(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("unable to release lock on %s: %w", locks[i].path, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// RefreshLock will refresh every lock in the set in the same way as Lock.RefreshLock. If any of them returns
+// ErrLockAbandoned, the whole set has been lost and the client should stop immediately.
+func (s *LockSet) RefreshLock(ctx context.Context) error {
+	var errs []error
+	for _, l := range s.locks {
+		if err := l.RefreshLock(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("unable to refresh lock on %s: %w", l.path, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// KeepAlive starts a background refresher for every lock in the set in the same way as Lock.KeepAlive. If any of them
+// is lost, ErrLockAbandoned is sent on the returned channel, which is closed once all the refreshers have stopped.
+func (s *LockSet) KeepAlive(ctx context.Context, interval time.Duration) <-chan error {
+	lost := make(chan error, 1)
+
+	var wg sync.WaitGroup
+	for _, l := range s.locks {
+		memberLost := l.KeepAlive(ctx, interval)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for err := range memberLost {
+				select {
+				case lost <- fmt.Errorf("lost lock on %s: %w", l.path, err):
+				default:
+					// The loss of the set has already been reported
+				}
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(lost)
+	}()
+
+	return lost
+}
+
+// FencingTokens returns the fencing token of each lock in the set, keyed by path, in the same way as
+// Lock.FencingToken.
+func (s *LockSet) FencingTokens() map[string]int64 {
+	tokens := make(map[string]int64, len(s.locks))
+	for _, l := range s.locks {
+		tokens[l.path] = l.FencingToken()
+	}
+
+	return tokens
+}
@@ -0,0 +1,86 @@
+package lock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewLockSet(t *testing.T) {
+	_, err := NewLockSet(newMemoryBackend(), "id", nil)
+	assert.ErrorContains(t, err, "at least one path must be given")
+
+	_, err = NewLockSet(newMemoryBackend(), "id", []string{"a", ""})
+	assert.ErrorContains(t, err, "path must not be empty")
+
+	s, err := NewLockSet(newMemoryBackend(), "id", []string{"c", "a", "b", "a"})
+	require.NoError(t, err)
+	var paths []string
+	for _, l := range s.locks {
+		paths = append(paths, l.path)
+	}
+	assert.Equal(t, []string{"a", "b", "c"}, paths)
+}
+
+func TestLockSet(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	t.Cleanup(cancel)
+
+	backend := newMemoryBackend()
+	newLockSet := func(identity string, paths ...string) *LockSet {
+		s, err := NewLockSet(backend, identity, paths, WithTTL(time.Minute), WithRetryInterval(10*time.Millisecond),
+			WithLogger(func(context.Context) Logger {
+				return loggerToTestingT{t}
+			}))
+		require.NoError(t, err)
+		return s
+	}
+	first := newLockSet("first", "b", "a")
+	second := newLockSet("second", "b", "0")
+
+	require.NoError(t, first.Lock(ctx, time.Second))
+	assert.Equal(t, map[string]int64{"a": 1, "b": 2}, first.FencingTokens())
+
+	// The second set rolls back the lock it did get when it can't get the rest
+	err := second.Lock(ctx, 100*time.Millisecond)
+	assert.ErrorIs(t, err, ErrTimeout)
+	assert.ErrorContains(t, err, "unable to acquire lock on b")
+	assert.NotContains(t, backend.objects, "0")
+
+	require.NoError(t, first.RefreshLock(ctx))
+	require.NoError(t, first.Unlock(ctx))
+	assert.Empty(t, backend.objects)
+
+	require.NoError(t, second.Lock(ctx, time.Second))
+	require.NoError(t, second.Unlock(ctx))
+}
+
+func TestLockSet_KeepAlive(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	t.Cleanup(cancel)
+
+	backend := newMemoryBackend()
+	s, err := NewLockSet(backend, "id", []string{"a", "b"}, WithTTL(time.Minute), WithMaxRefreshFailures(0))
+	require.NoError(t, err)
+	require.NoError(t, s.Lock(ctx, time.Second))
+
+	lost := s.KeepAlive(ctx, 10*time.Millisecond)
+
+	// Losing one of the locks loses the set
+	require.NoError(t, backend.Delete(ctx, "b", Conditions{}))
+	select {
+	case err := <-lost:
+		assert.ErrorIs(t, err, ErrLockAbandoned)
+		assert.ErrorContains(t, err, "lost lock on b")
+	case <-time.After(5 * time.Second):
+		t.Fatal("loss of the set wasn't reported")
+	}
+	assert.ErrorIs(t, s.RefreshLock(ctx), ErrLockAbandoned)
+
+	require.NoError(t, s.Unlock(ctx))
+	for range lost {
+	}
+}
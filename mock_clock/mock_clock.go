@@ -0,0 +1,119 @@
+package mock_clock // nolint:revive // Nothing wrong with underscore in a name
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock is a fake clock for testing, which only moves when advanced. It can be given to a lock with WithClock, and to
+// mock_gcs.NewServer so that the times of objects follow it too.
+type Clock struct {
+	m      sync.Mutex
+	now    time.Time
+	timers []*timer
+	// next is used to order timers due at the same time by when they were created
+	next int
+}
+
+type timer struct {
+	at  time.Time
+	seq int
+	f   func()
+}
+
+// NewClock creates a fake clock starting at the given time.
+func NewClock(now time.Time) *Clock {
+	return &Clock{now: now}
+}
+
+// Now returns the current time of the clock.
+func (c *Clock) Now() time.Time {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	return c.now
+}
+
+// AfterFunc calls f once the clock has been advanced by the duration, unless the returned function is called first to
+// stop it. f is called by Advance, so must not block, and is called straight away if the duration isn't positive.
+func (c *Clock) AfterFunc(d time.Duration, f func()) func() bool {
+	if d <= 0 {
+		f()
+		return func() bool { return false }
+	}
+
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	t := &timer{at: c.now.Add(d), seq: c.next, f: f}
+	c.next++
+	c.timers = append(c.timers, t)
+
+	return func() bool {
+		c.m.Lock()
+		defer c.m.Unlock()
+
+		for i, pending := range c.timers {
+			if pending == t {
+				c.timers = append(c.timers[:i], c.timers[i+1:]...)
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Advance moves the clock forward by the duration, calling the functions of any timers which become due in the order
+// they're due, with the clock set to the time each was due. Timers added by those functions are also called if they
+// become due before the end of the duration.
+func (c *Clock) Advance(d time.Duration) {
+	c.m.Lock()
+	end := c.now.Add(d)
+	c.m.Unlock()
+
+	for {
+		c.m.Lock()
+		t := c.popDue(end)
+		if t == nil {
+			c.now = end
+			c.m.Unlock()
+			return
+		}
+		c.now = t.at
+		c.m.Unlock()
+
+		t.f()
+	}
+}
+
+// Timers returns how many timers are waiting to be called, so that tests can wait for the code under test to start
+// waiting before advancing the clock.
+func (c *Clock) Timers() int {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	return len(c.timers)
+}
+
+// popDue removes and returns the earliest timer due by the given time, and must be called while holding the lock.
+func (c *Clock) popDue(by time.Time) *timer {
+	if len(c.timers) == 0 {
+		return nil
+	}
+
+	sort.Slice(c.timers, func(i, j int) bool {
+		if !c.timers[i].at.Equal(c.timers[j].at) {
+			return c.timers[i].at.Before(c.timers[j].at)
+		}
+		return c.timers[i].seq < c.timers[j].seq
+	})
+
+	t := c.timers[0]
+	if t.at.After(by) {
+		return nil
+	}
+	c.timers = c.timers[1:]
+
+	return t
+}
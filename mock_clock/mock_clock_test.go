@@ -0,0 +1,57 @@
+package mock_clock // nolint:revive // Nothing wrong with underscore in a name
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClock_Advance(t *testing.T) {
+	start := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+	subject := NewClock(start)
+	assert.Equal(t, start, subject.Now())
+
+	var called []string
+	var at []time.Time
+	record := func(name string) func() {
+		return func() {
+			called = append(called, name)
+			at = append(at, subject.Now())
+		}
+	}
+
+	subject.AfterFunc(2*time.Second, record("second"))
+	subject.AfterFunc(time.Second, record("first"))
+	stop := subject.AfterFunc(time.Second, record("stopped"))
+	subject.AfterFunc(time.Second, func() {
+		record("rescheduling")()
+		subject.AfterFunc(500*time.Millisecond, record("rescheduled"))
+	})
+	subject.AfterFunc(time.Minute, record("later"))
+
+	assert.True(t, stop())
+	assert.False(t, stop())
+	assert.Equal(t, 4, subject.Timers())
+
+	subject.AfterFunc(0, record("immediate"))
+	assert.Equal(t, []string{"immediate"}, called)
+
+	subject.Advance(2 * time.Second)
+	assert.Equal(t, []string{"immediate", "first", "rescheduling", "rescheduled", "second"}, called)
+	assert.Equal(t, []time.Time{
+		start,
+		start.Add(time.Second),
+		start.Add(time.Second),
+		start.Add(1500 * time.Millisecond),
+		start.Add(2 * time.Second),
+	}, at)
+	assert.Equal(t, start.Add(2*time.Second), subject.Now())
+	assert.Equal(t, 1, subject.Timers())
+
+	subject.Advance(time.Hour)
+	assert.Equal(t, "later", called[len(called)-1])
+	assert.Equal(t, start.Add(time.Minute), at[len(at)-1])
+	assert.Equal(t, start.Add(2*time.Second+time.Hour), subject.Now())
+	assert.Zero(t, subject.Timers())
+}
@@ -0,0 +1,134 @@
+package mock_gcs // nolint:revive // Nothing wrong with underscore in a name
+
+import (
+	"net/http"
+	"time"
+)
+
+// Fault describes how the server should misbehave for the requests it matches. Requests which match a fault are
+// delayed by the latency, if any, and then either fail with the status, have their connection dropped part way through
+// the response, hang until the request is cancelled or the server is closed, or are otherwise handled as normal.
+type Fault struct {
+	// Method is the HTTP method of the requests to match, or empty to match any method
+	Method string
+	// Object is the name of the object to match, or empty to match requests for any object, including listings
+	Object string
+	// After is the number of matching requests to let through before the fault applies, so the Nth matching request
+	// fails if it's N-1
+	After int
+	// Times is the number of matching requests which the fault applies to, or zero for every request after the first
+	// After
+	Times int
+
+	// Latency delays the request before it's handled
+	Latency time.Duration
+	// Status fails the request with the given status code, such as http.StatusTooManyRequests or
+	// http.StatusServiceUnavailable
+	Status int
+	// Drop closes the connection after the response has started, without completing it
+	Drop bool
+	// Hang blocks the request until the client gives up on it or the server is closed
+	Hang bool
+}
+
+// fault tracks how many requests a Fault has matched so far.
+type fault struct {
+	Fault
+	matched int
+}
+
+// WithFaults configures the server with an initial fault plan. See SetFaults.
+func WithFaults(faults ...Fault) Opt {
+	return func(s *Server) {
+		s.setFaults(faults)
+	}
+}
+
+// SetFaults replaces the fault plan, which can be changed while requests are being handled. Every fault counts the
+// requests it matches from when it was added, and the first fault in the plan which applies to a request is used.
+// Calling it without any faults restores normal behaviour.
+func (s *Server) SetFaults(faults ...Fault) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	s.setFaults(faults)
+}
+
+// AddFault adds a fault to the end of the fault plan, leaving the existing faults and their counts as they are.
+func (s *Server) AddFault(f Fault) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	s.faults = append(s.faults, &fault{Fault: f})
+}
+
+// setFaults replaces the fault plan, and must be called while holding the lock.
+func (s *Server) setFaults(faults []Fault) {
+	s.faults = make([]*fault, 0, len(faults))
+	for _, f := range faults {
+		s.faults = append(s.faults, &fault{Fault: f})
+	}
+}
+
+// matchFault returns the fault which applies to the request, if any, counting the request against every fault it
+// matches.
+func (s *Server) matchFault(r *http.Request) *Fault {
+	object := objectName(r)
+
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	var matched *Fault
+	for _, f := range s.faults {
+		if (f.Method != "" && f.Method != r.Method) || (f.Object != "" && f.Object != object) {
+			continue
+		}
+
+		f.matched++
+		if matched == nil && f.matched > f.After && (f.Times == 0 || f.matched <= f.After+f.Times) {
+			matched = &f.Fault
+		}
+	}
+
+	return matched
+}
+
+// injectFault applies any fault matching the request, returning true if the request has been dealt with.
+func (s *Server) injectFault(w http.ResponseWriter, r *http.Request) bool {
+	f := s.matchFault(r)
+	if f == nil {
+		return false
+	}
+
+	if f.Latency > 0 {
+		select {
+		case <-time.After(f.Latency):
+		case <-r.Context().Done():
+			return true
+		case <-s.closed:
+			return true
+		}
+	}
+
+	switch {
+	case f.Hang:
+		select {
+		case <-r.Context().Done():
+		case <-s.closed:
+		}
+		return true
+	case f.Drop:
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(`{"kind": "storage#`))
+		if flusher, ok := w.(http.Flusher); ok {
+			flusher.Flush()
+		}
+		panic(http.ErrAbortHandler)
+	case f.Status != 0:
+		http.Error(w, "injected fault", f.Status)
+		return true
+	}
+
+	return false
+}
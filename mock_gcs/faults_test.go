@@ -0,0 +1,148 @@
+package mock_gcs // nolint:revive // Nothing wrong with underscore in a name
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/googleapi"
+)
+
+func TestServer_Faults(t *testing.T) {
+	tests := []struct {
+		name     string
+		fault    Fault
+		expected []int
+	}{
+		{
+			name:     "fails every matching request",
+			fault:    Fault{Method: http.MethodGet, Object: "object", Status: http.StatusServiceUnavailable},
+			expected: []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusServiceUnavailable},
+		},
+		{
+			name:     "fails the nth matching request",
+			fault:    Fault{Object: "object", After: 1, Times: 1, Status: http.StatusTooManyRequests},
+			expected: []int{http.StatusOK, http.StatusTooManyRequests, http.StatusOK},
+		},
+		{
+			name:     "fails from the nth matching request",
+			fault:    Fault{After: 1, Status: http.StatusInternalServerError},
+			expected: []int{http.StatusOK, http.StatusInternalServerError, http.StatusInternalServerError},
+		},
+		{
+			name:     "ignores other methods",
+			fault:    Fault{Method: http.MethodPatch, Status: http.StatusPreconditionFailed},
+			expected: []int{http.StatusOK, http.StatusOK, http.StatusOK},
+		},
+		{
+			name:     "ignores other objects",
+			fault:    Fault{Object: "other", Status: http.StatusPreconditionFailed},
+			expected: []int{http.StatusOK, http.StatusOK, http.StatusOK},
+		},
+		{
+			name:     "only delays",
+			fault:    Fault{Latency: time.Millisecond},
+			expected: []int{http.StatusOK, http.StatusOK, http.StatusOK},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			subject := NewServer("b", WithFaults(test.fault))
+			subject.Add("object", storage.ObjectAttrs{Generation: 1, Metageneration: 1})
+			t.Cleanup(subject.Close)
+
+			client, err := subject.Client(context.Background())
+			require.NoError(t, err)
+
+			client.SetRetry(storage.WithMaxAttempts(1))
+
+			var actual []int
+			for range test.expected {
+				actual = append(actual, statusOf(client.Bucket("b").Object("object").Attrs(context.Background())))
+			}
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
+
+func TestServer_SetFaults(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	t.Cleanup(cancel)
+
+	subject := NewServer("b")
+	subject.Add("object", storage.ObjectAttrs{Generation: 1, Metageneration: 1})
+	t.Cleanup(subject.Close)
+
+	client, err := subject.Client(ctx)
+	require.NoError(t, err)
+
+	client.SetRetry(storage.WithMaxAttempts(1))
+	object := client.Bucket("b").Object("object")
+
+	subject.SetFaults(Fault{Latency: 50 * time.Millisecond})
+	start := time.Now()
+	_, err = object.Attrs(ctx)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+
+	subject.SetFaults(Fault{Drop: true})
+	_, err = object.Attrs(ctx)
+	assert.Error(t, err)
+
+	subject.SetFaults(Fault{Hang: true})
+	hangCtx, hangCancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer hangCancel()
+	_, err = object.Attrs(hangCtx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// Faults which are added keep to their own counts
+	subject.SetFaults()
+	subject.AddFault(Fault{Method: http.MethodDelete, Status: http.StatusServiceUnavailable, Times: 1})
+	assert.Equal(t, http.StatusServiceUnavailable, statusOf(nil, object.Delete(ctx)))
+	assert.Equal(t, http.StatusOK, statusOf(nil, object.Delete(ctx)))
+	assert.Nil(t, subject.Get("object"))
+}
+
+func TestServer_Close_ReleasesHangingRequests(t *testing.T) {
+	subject := NewServer("b", WithFaults(Fault{Hang: true}))
+
+	client, err := subject.Client(context.Background())
+	require.NoError(t, err)
+
+	client.SetRetry(storage.WithMaxAttempts(1))
+
+	done := make(chan error)
+	go func() {
+		_, err := client.Bucket("b").Object("object").Attrs(context.Background())
+		done <- err
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	subject.Close()
+
+	select {
+	case err := <-done:
+		assert.Error(t, err)
+	case <-time.After(10 * time.Second):
+		t.Fatal("hanging request wasn't released")
+	}
+}
+
+// statusOf returns the status code of the response which led to the error, or http.StatusOK if there wasn't one.
+func statusOf(_ any, err error) int {
+	if err == nil {
+		return http.StatusOK
+	}
+
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) {
+		return apiErr.Code
+	}
+	return 0
+}
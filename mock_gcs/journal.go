@@ -0,0 +1,169 @@
+package mock_gcs // nolint:revive // Nothing wrong with underscore in a name
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+)
+
+// preconditions are the query parameters which Google Cloud Storage accepts as preconditions.
+var preconditions = []string{"ifGenerationMatch", "ifGenerationNotMatch", "ifMetagenerationMatch", "ifMetagenerationNotMatch"}
+
+// Request is a request recorded in the journal of the server.
+type Request struct {
+	Method string
+	// Object is the name of the object requested, or empty for listings
+	Object string
+	// Preconditions holds the preconditions given in the query, such as ifGenerationMatch, and their values
+	Preconditions map[string]string
+	// Metadata holds the metadata sent when creating or updating an object, unless the request failed before it was read
+	Metadata map[string]string
+	// Status is the status of the response, or zero if the request was dropped before one was sent
+	Status int
+}
+
+// journalKey is the context key for the journal entry of a request.
+type journalKey struct{}
+
+// WithoutJournal configures the server not to record the requests made to it, such as when it's long-lived.
+func WithoutJournal() Opt {
+	return func(s *Server) {
+		s.withoutJournal = true
+	}
+}
+
+// Requests returns every request made to the server, in the order they were received.
+func (s *Server) Requests() []Request {
+	return s.RequestsFor("", "")
+}
+
+// RequestsFor returns the requests made to the server with the method for the object, in the order they were received.
+// An empty method or object matches any.
+func (s *Server) RequestsFor(method, object string) []Request {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	var requests []Request
+	for _, request := range s.journal {
+		if (method != "" && method != request.Method) || (object != "" && object != request.Object) {
+			continue
+		}
+
+		requests = append(requests, Request{
+			Method:        request.Method,
+			Object:        request.Object,
+			Preconditions: maps.Clone(request.Preconditions),
+			Metadata:      maps.Clone(request.Metadata),
+			Status:        request.Status,
+		})
+	}
+
+	return requests
+}
+
+// ResetRequests clears the journal, so that only requests made afterwards are returned.
+func (s *Server) ResetRequests() {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	s.journal = nil
+}
+
+// CheckPreconditions returns an error unless at least one request has been made with the method for the object, and
+// every one of them was made with all the named preconditions, such as ifGenerationMatch. An empty method or object
+// matches any.
+func (s *Server) CheckPreconditions(method, object string, names ...string) error {
+	requests := s.RequestsFor(method, object)
+	if len(requests) == 0 {
+		return fmt.Errorf("no %s requests for %q", method, object)
+	}
+
+	var errs []error
+	for i, request := range requests {
+		for _, name := range names {
+			if _, ok := request.Preconditions[name]; !ok {
+				errs = append(errs, fmt.Errorf("%s request %d for %q is missing precondition %s", method, i, object, name))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// recordRequest adds the request to the journal, returning the request to serve, which gives access to the entry, and
+// a function to record the status of the response once it's been handled.
+func (s *Server) recordRequest(w http.ResponseWriter, r *http.Request) (http.ResponseWriter, *http.Request, func()) {
+	entry := &Request{
+		Method:        r.Method,
+		Object:        objectName(r),
+		Preconditions: map[string]string{},
+	}
+	query := r.URL.Query()
+	for _, name := range preconditions {
+		if query.Has(name) {
+			entry.Preconditions[name] = query.Get(name)
+		}
+	}
+
+	s.m.Lock()
+	if !s.withoutJournal {
+		s.journal = append(s.journal, entry)
+	}
+	s.m.Unlock()
+
+	recorder := &statusRecorder{ResponseWriter: w}
+	return recorder, r.WithContext(context.WithValue(r.Context(), journalKey{}, entry)), func() {
+		s.m.Lock()
+		defer s.m.Unlock()
+
+		entry.Status = recorder.status
+	}
+}
+
+// recordMetadata records the metadata sent with the request in the journal, and must be called while holding the lock.
+func recordMetadata(r *http.Request, metadata map[string]string) {
+	if entry, ok := r.Context().Value(journalKey{}).(*Request); ok {
+		entry.Metadata = maps.Clone(metadata)
+	}
+}
+
+// objectName returns the name of the object the request is for, or empty if it isn't for an object.
+func objectName(r *http.Request) string {
+	if object := r.PathValue("object"); object != "" {
+		return object
+	}
+
+	// Uploads give the name of the object in the query
+	return r.URL.Query().Get("name")
+}
+
+// statusRecorder records the status of the response written through it.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *statusRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
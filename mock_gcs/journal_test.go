@@ -0,0 +1,81 @@
+package mock_gcs // nolint:revive // Nothing wrong with underscore in a name
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_Requests(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	t.Cleanup(cancel)
+
+	subject := NewServer("b")
+	t.Cleanup(subject.Close)
+
+	client, err := subject.Client(ctx)
+	require.NoError(t, err)
+
+	client.SetRetry(storage.WithMaxAttempts(1))
+	object := client.Bucket("b").Object("object")
+
+	w := object.If(storage.Conditions{DoesNotExist: true}).NewWriter(ctx)
+	w.Metadata = map[string]string{"k": "v"}
+	require.NoError(t, w.Close())
+
+	_, err = object.If(storage.Conditions{GenerationMatch: 1, MetagenerationMatch: 2}).
+		Update(ctx, storage.ObjectAttrsToUpdate{Metadata: map[string]string{"k": "changed"}})
+	require.Error(t, err)
+
+	subject.AddFault(Fault{Method: http.MethodDelete, Status: http.StatusServiceUnavailable})
+	require.Error(t, object.Delete(ctx))
+
+	_, err = client.Bucket("different").Object("object").Attrs(ctx)
+	require.Error(t, err)
+
+	assert.Equal(t, []Request{
+		{
+			Method:        http.MethodPost,
+			Object:        "object",
+			Preconditions: map[string]string{"ifGenerationMatch": "0"},
+			Metadata:      map[string]string{"k": "v"},
+			Status:        http.StatusOK,
+		},
+		{
+			Method:        http.MethodPatch,
+			Object:        "object",
+			Preconditions: map[string]string{"ifGenerationMatch": "1", "ifMetagenerationMatch": "2"},
+			Metadata:      map[string]string{"k": "changed"},
+			Status:        http.StatusPreconditionFailed,
+		},
+		{
+			Method:        http.MethodDelete,
+			Object:        "object",
+			Preconditions: map[string]string{},
+			Status:        http.StatusServiceUnavailable,
+		},
+		{
+			Method:        http.MethodGet,
+			Object:        "object",
+			Preconditions: map[string]string{},
+			Status:        599,
+		},
+	}, subject.Requests())
+
+	assert.Len(t, subject.RequestsFor(http.MethodPatch, ""), 1)
+	assert.Len(t, subject.RequestsFor("", "object"), 4)
+	assert.Empty(t, subject.RequestsFor("", "other"))
+
+	assert.NoError(t, subject.CheckPreconditions(http.MethodPatch, "object", "ifGenerationMatch", "ifMetagenerationMatch"))
+	assert.EqualError(t, subject.CheckPreconditions(http.MethodDelete, "object", "ifGenerationMatch"),
+		`DELETE request 0 for "object" is missing precondition ifGenerationMatch`)
+	assert.EqualError(t, subject.CheckPreconditions(http.MethodPut, "object"), `no PUT requests for "object"`)
+
+	subject.ResetRequests()
+	assert.Empty(t, subject.Requests())
+}
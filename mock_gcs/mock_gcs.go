@@ -4,13 +4,17 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"maps"
 	"mime"
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"cloud.google.com/go/storage"
 	"google.golang.org/api/option"
@@ -19,13 +23,30 @@ import (
 
 // Server is a mock Google Cloud Storage server for testing.
 type Server struct {
-	m      sync.Mutex
-	data   map[string]*v1.Object
-	bucket string
-	server *httptest.Server
+	m       sync.Mutex
+	data    map[string]*v1.Object
+	bucket  string
+	handler http.Handler
+	server  *httptest.Server
+	// generation is the last generation used, which increases with every object created, as in Google Cloud Storage
+	generation int64
 
 	failOnObjectExistence bool
 	failOnObjectName      *string
+	clock                 Clock
+
+	// faults is the fault plan, which is applied to requests before they're handled
+	faults []*fault
+	// journal records every request made to the server, unless disabled
+	journal        []*Request
+	withoutJournal bool
+	// closed is closed when the server is, to release any requests which are hanging
+	closed chan struct{}
+}
+
+// Clock tells the time, for the creation and update times of objects.
+type Clock interface {
+	Now() time.Time
 }
 
 // Opt is a function type for configuring the mock server.
@@ -45,6 +66,14 @@ func WithFailOnObjectName(name string) Opt {
 	}
 }
 
+// WithClock configures the server to use the clock for the creation and update times of objects, rather than the system
+// time, such as to share a fake clock with the lock under test.
+func WithClock(clock Clock) Opt {
+	return func(s *Server) {
+		s.clock = clock
+	}
+}
+
 // NewServer creates a new mock Google Cloud Storage server.
 func NewServer(bucket string, opts ...Opt) *Server {
 	server := &Server{
@@ -52,6 +81,7 @@ func NewServer(bucket string, opts ...Opt) *Server {
 		data:                  map[string]*v1.Object{},
 		bucket:                bucket,
 		failOnObjectExistence: false,
+		closed:                make(chan struct{}),
 	}
 
 	for _, opt := range opts {
@@ -60,25 +90,40 @@ func NewServer(bucket string, opts ...Opt) *Server {
 
 	mux := http.NewServeMux()
 	mux.Handle("POST /upload/storage/v1/b/{bucket}/o", server.validateRequest(server.createObject))
-	mux.Handle("GET /b/{bucket}/o/{object...}", server.validateRequest(server.readObject))
-	mux.Handle("DELETE /b/{bucket}/o/{object...}", server.validateRequest(server.deleteObject))
-	mux.Handle("PATCH /b/{bucket}/o/{object...}", server.validateRequest(server.updateObject))
-	mux.Handle("GET /b/{bucket}/o", server.validateRequest(server.listObjects))
+	// Clients using STORAGE_EMULATOR_HOST include the version of the API in the path
+	for _, prefix := range []string{"", "/storage/v1"} {
+		mux.Handle("GET "+prefix+"/b/{bucket}/o/{object...}", server.validateRequest(server.readObject))
+		mux.Handle("DELETE "+prefix+"/b/{bucket}/o/{object...}", server.validateRequest(server.deleteObject))
+		mux.Handle("PATCH "+prefix+"/b/{bucket}/o/{object...}", server.validateRequest(server.updateObject))
+		mux.Handle("GET "+prefix+"/b/{bucket}/o", server.validateRequest(server.listObjects))
+	}
 	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, fmt.Sprintf("%s %s not handled", r.Method, r.URL.Path), 550)
 	})
-	server.server = httptest.NewUnstartedServer(mux)
+	server.handler = mux
 	return server
 }
 
+// Handler returns the handler which serves the requests to the mock server, so it can be served in other ways than
+// through Client.
+func (s *Server) Handler() http.Handler {
+	return s.handler
+}
+
 // Close shuts down the mock server.
 func (s *Server) Close() {
-	s.server.Close()
+	close(s.closed)
+	if s.server != nil {
+		s.server.Close()
+	}
 }
 
-// Client returns a Google Cloud Storage client configured to use this mock server.
+// Client returns a Google Cloud Storage client configured to use this mock server, which is started the first time
+// it's called.
 func (s *Server) Client(ctx context.Context) (*storage.Client, error) {
-	s.server.StartTLS()
+	if s.server == nil {
+		s.server = httptest.NewTLSServer(s.handler)
+	}
 
 	return storage.NewClient(ctx, option.WithHTTPClient(s.server.Client()), option.WithoutAuthentication(), option.WithEndpoint(s.server.URL))
 }
@@ -88,6 +133,7 @@ func (s *Server) Add(name string, attrs storage.ObjectAttrs) { // nolint:gocriti
 	s.m.Lock()
 	defer s.m.Unlock()
 
+	s.generation = max(s.generation, attrs.Generation)
 	s.data[name] = &v1.Object{
 		Id:             name,
 		Kind:           "storage#object",
@@ -97,6 +143,8 @@ func (s *Server) Add(name string, attrs storage.ObjectAttrs) { // nolint:gocriti
 		Bucket:         s.bucket,
 		CacheControl:   attrs.CacheControl,
 		Generation:     attrs.Generation,
+		TimeCreated:    formatTime(attrs.Created),
+		Updated:        formatTime(attrs.Updated),
 	}
 }
 
@@ -117,6 +165,8 @@ func (s *Server) Get(name string) *storage.ObjectAttrs {
 		Metadata:       obj.Metadata,
 		Generation:     obj.Generation,
 		Metageneration: obj.Metageneration,
+		Created:        parseTime(obj.TimeCreated),
+		Updated:        parseTime(obj.Updated),
 	}
 }
 
@@ -130,10 +180,16 @@ func (s *Server) RemoveAll() {
 
 func (s *Server) validateRequest(next func(http.ResponseWriter, *http.Request)) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w, r, recorded := s.recordRequest(w, r)
+		defer recorded()
+
 		if r.PathValue("bucket") != s.bucket {
 			http.Error(w, "incorrect bucket", 599)
 			return
 		}
+		if s.injectFault(w, r) {
+			return
+		}
 		http.HandlerFunc(next).ServeHTTP(w, r)
 	})
 }
@@ -146,9 +202,9 @@ func (s *Server) listObjects(w http.ResponseWriter, r *http.Request) {
 
 	objs := v1.Objects{}
 
-	for name, o := range s.data {
+	for _, name := range s.names() {
 		if strings.HasPrefix(name, prefix) {
-			objs.Items = append(objs.Items, o)
+			objs.Items = append(objs.Items, s.data[name])
 		}
 	}
 
@@ -192,21 +248,26 @@ func (s *Server) createObject(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if s.failOnObjectExistence {
-		query := r.URL.Query()
-		if !query.Has("ifGenerationMatch") || query.Get("ifGenerationMatch") != "0" {
-			http.Error(w, "createObject missing ifGenerationMatch", http.StatusNotImplemented)
-			return
-		}
+	s.m.Lock()
+	defer s.m.Unlock()
 
-		if _, ok := s.data[objectAttrs.Name]; ok {
-			http.Error(w, "createObject already has that object", http.StatusPreconditionFailed)
-			return
-		}
+	recordMetadata(r, objectAttrs.Metadata)
+
+	query := r.URL.Query()
+	if s.failOnObjectExistence && (!query.Has("ifGenerationMatch") || query.Get("ifGenerationMatch") != "0") {
+		http.Error(w, "createObject missing ifGenerationMatch", http.StatusNotImplemented)
+		return
+	}
+
+	if status, msg := checkPreconditions(query, s.data[objectAttrs.Name], false); status != http.StatusOK {
+		http.Error(w, "createObject "+msg, status)
+		return
 	}
 
+	s.generation++
+	now := formatTime(s.now())
 	object := v1.Object{
-		Generation:     1,
+		Generation:     s.generation,
 		Id:             "doo",
 		Kind:           "storage#object",
 		Metadata:       objectAttrs.Metadata,
@@ -214,10 +275,10 @@ func (s *Server) createObject(w http.ResponseWriter, r *http.Request) {
 		Name:           objectAttrs.Name,
 		CacheControl:   objectAttrs.CacheControl,
 		Bucket:         objectAttrs.Bucket,
+		TimeCreated:    now,
+		Updated:        now,
 	}
 
-	s.m.Lock()
-	defer s.m.Unlock()
 	s.data[object.Name] = &object
 
 	w.WriteHeader(200)
@@ -238,6 +299,14 @@ func (s *Server) readObject(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if status, msg := checkPreconditions(r.URL.Query(), obj, true); status == http.StatusNotModified {
+		w.WriteHeader(status)
+		return
+	} else if status != http.StatusOK {
+		http.Error(w, "readObject "+msg, status)
+		return
+	}
+
 	w.WriteHeader(200)
 	if err := json.NewEncoder(w).Encode(obj); err != nil {
 		panic(err)
@@ -258,28 +327,45 @@ func (s *Server) updateObject(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Only the metadata is patched, which is kept apart from the other fields to tell it being cleared from it being left
+	var patch struct {
+		Metadata json.RawMessage `json:"metadata"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+		http.Error(w, fmt.Sprintf("updateObject failed to read body: %s", err), 599)
+		return
+	}
+	var metadata map[string]*string
+	if err := json.Unmarshal(patch.Metadata, &metadata); len(patch.Metadata) > 0 && err != nil {
+		http.Error(w, fmt.Sprintf("updateObject failed to read metadata: %s", err), 599)
+		return
+	}
+
 	s.m.Lock()
 	defer s.m.Unlock()
 
+	sent := map[string]string{}
+	for key, value := range metadata {
+		if value != nil {
+			sent[key] = *value
+		}
+	}
+	recordMetadata(r, sent)
+
 	obj, ok := s.data[name]
 	if !ok {
 		http.NotFound(w, r)
 		return
 	}
 
-	if strconv.FormatInt(obj.Metageneration, 10) != query.Get("ifMetagenerationMatch") {
-		http.Error(w, "updateObject with old metageneration", http.StatusPreconditionFailed)
+	if status, msg := checkPreconditions(query, obj, false); status != http.StatusOK {
+		http.Error(w, "updateObject "+msg, status)
 		return
 	}
 
-	var objectAttrs storage.ObjectAttrs
-	if err := json.NewDecoder(r.Body).Decode(&objectAttrs); err != nil {
-		http.Error(w, fmt.Sprintf("createObject failed to read body: %s", err), 599)
-		return
-	}
-
-	obj.Metadata = objectAttrs.Metadata
+	obj.Metadata = patchMetadata(obj.Metadata, patch.Metadata, metadata)
 	obj.Metageneration++
+	obj.Updated = formatTime(s.now())
 
 	w.WriteHeader(200)
 	if err := json.NewEncoder(w).Encode(obj); err != nil {
@@ -287,6 +373,30 @@ func (s *Server) updateObject(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// patchMetadata merges the metadata sent with an update into the existing metadata, as GCS does. Keys sent as null
+// are removed, and sending null or an empty map instead removes all of them.
+func patchMetadata(existing map[string]string, raw json.RawMessage, metadata map[string]*string) map[string]string {
+	if len(raw) == 0 {
+		return existing
+	}
+	if len(metadata) == 0 {
+		return nil
+	}
+
+	patched := maps.Clone(existing)
+	if patched == nil {
+		patched = map[string]string{}
+	}
+	for key, value := range metadata {
+		if value == nil {
+			delete(patched, key)
+		} else {
+			patched[key] = *value
+		}
+	}
+	return patched
+}
+
 func (s *Server) deleteObject(w http.ResponseWriter, r *http.Request) {
 	name := r.PathValue("object")
 
@@ -312,14 +422,91 @@ func (s *Server) deleteObject(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if s.failOnObjectExistence {
-		if strconv.FormatInt(obj.Metageneration, 10) != query.Get("ifMetagenerationMatch") {
-			http.Error(w, "deleteObject with old metageneration", http.StatusPreconditionFailed)
-			return
-		}
+	if status, msg := checkPreconditions(query, obj, false); status != http.StatusOK {
+		http.Error(w, "deleteObject "+msg, status)
+		return
 	}
 
 	delete(s.data, name)
 
 	w.WriteHeader(204)
 }
+
+// checkPreconditions checks the generation and metageneration preconditions in the query against the object, which is
+// nil if it doesn't exist, in the same way as Google Cloud Storage. It returns the status to fail the request with, or
+// http.StatusOK if the preconditions hold. Reads which fail a NotMatch precondition haven't been modified, rather than
+// failing, if notModified is set.
+func checkPreconditions(query url.Values, obj *v1.Object, notModified bool) (int, string) {
+	var generation, metageneration int64
+	if obj != nil {
+		generation, metageneration = obj.Generation, obj.Metageneration
+	}
+
+	for _, condition := range []struct {
+		name   string
+		actual int64
+		match  bool
+	}{
+		{"ifGenerationMatch", generation, true},
+		{"ifGenerationNotMatch", generation, false},
+		{"ifMetagenerationMatch", metageneration, true},
+		{"ifMetagenerationNotMatch", metageneration, false},
+	} {
+		if !query.Has(condition.name) {
+			continue
+		}
+
+		expected, err := strconv.ParseInt(query.Get(condition.name), 10, 64)
+		if err != nil {
+			return http.StatusBadRequest, fmt.Sprintf("invalid %s: %s", condition.name, err)
+		}
+		if (expected == condition.actual) == condition.match {
+			continue
+		}
+
+		if !condition.match && notModified {
+			return http.StatusNotModified, ""
+		}
+		return http.StatusPreconditionFailed, fmt.Sprintf("failed %s %d", condition.name, expected)
+	}
+
+	return http.StatusOK, ""
+}
+
+// now returns the current time by the clock, if there is one.
+func (s *Server) now() time.Time {
+	if s.clock == nil {
+		return time.Now()
+	}
+
+	return s.clock.Now()
+}
+
+// names returns the names of all objects in order, and must be called while holding the lock.
+func (s *Server) names() []string {
+	names := make([]string, 0, len(s.data))
+	for name := range s.data {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	return names
+}
+
+// formatTime formats a time in the same way as Google Cloud Storage, leaving it empty if unset.
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+func parseTime(s string) time.Time {
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return time.Time{}
+	}
+
+	return t
+}
@@ -3,11 +3,16 @@ package mock_gcs // nolint:revive // Nothing wrong with underscore in a name
 import (
 	"context"
 	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"cloud.google.com/go/storage"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/api/googleapi"
 	"google.golang.org/api/iterator"
 )
 
@@ -155,6 +160,10 @@ func TestGCS_CreateObject(t *testing.T) {
 			require.NoError(t, err)
 
 			actual := subject.Get(test.objectName)
+			assert.WithinDuration(t, time.Now(), actual.Created, time.Minute)
+			assert.Equal(t, actual.Created, actual.Updated)
+			actual.Created = time.Time{}
+			actual.Updated = time.Time{}
 			assert.Equal(t, test.expected, *actual)
 		})
 	}
@@ -166,6 +175,7 @@ func TestGCS_ReadObject(t *testing.T) {
 		bucket        string
 		initialObject storage.ObjectAttrs
 		objectName    string
+		condition     *storage.Conditions
 		expectedError bool
 		expected      storage.ObjectAttrs
 	}{
@@ -199,6 +209,36 @@ func TestGCS_ReadObject(t *testing.T) {
 				Retention:      nil,
 			},
 		},
+		{
+			name:       "fails to read object if wrong generation",
+			bucket:     "b",
+			objectName: "object",
+			initialObject: storage.ObjectAttrs{
+				Bucket:         "b",
+				Name:           "object",
+				CacheControl:   "no-cache",
+				Metadata:       map[string]string{"k": "v"},
+				Generation:     5,
+				Metageneration: 3,
+			},
+			condition:     &storage.Conditions{GenerationMatch: 4},
+			expectedError: true,
+		},
+		{
+			name:       "fails to read object if not modified",
+			bucket:     "b",
+			objectName: "object",
+			initialObject: storage.ObjectAttrs{
+				Bucket:         "b",
+				Name:           "object",
+				CacheControl:   "no-cache",
+				Metadata:       map[string]string{"k": "v"},
+				Generation:     5,
+				Metageneration: 3,
+			},
+			condition:     &storage.Conditions{MetagenerationNotMatch: 3},
+			expectedError: true,
+		},
 		{
 			name:          "unknown object",
 			bucket:        "b",
@@ -219,7 +259,11 @@ func TestGCS_ReadObject(t *testing.T) {
 
 			client.SetRetry(storage.WithMaxAttempts(1))
 
-			attrs, err := client.Bucket(test.bucket).Object(test.objectName).Attrs(context.Background())
+			object := client.Bucket(test.bucket).Object(test.objectName)
+			if test.condition != nil {
+				object = object.If(*test.condition)
+			}
+			attrs, err := object.Attrs(context.Background())
 
 			if test.expectedError {
 				assert.Error(t, err)
@@ -285,6 +329,21 @@ func TestGCS_UpdateObject(t *testing.T) {
 			condition:     &storage.Conditions{MetagenerationMatch: 2},
 			expectedError: true,
 		},
+		{
+			name:       "fails to update object if wrong generation",
+			bucket:     "b",
+			objectName: "object",
+			initialObject: storage.ObjectAttrs{
+				Bucket:         "b",
+				Name:           "object",
+				CacheControl:   "no-cache",
+				Metadata:       map[string]string{"k": "v"},
+				Generation:     5,
+				Metageneration: 3,
+			},
+			condition:     &storage.Conditions{GenerationMatch: 4, MetagenerationMatch: 3},
+			expectedError: true,
+		},
 		{
 			name:          "requires metageneration condition",
 			bucket:        "b",
@@ -330,20 +389,72 @@ func TestGCS_UpdateObject(t *testing.T) {
 				// this doesn't get set to nil by the client
 				attrs.MD5 = nil
 			}
+			assert.WithinDuration(t, time.Now(), attrs.Updated, time.Minute)
+			attrs.Updated = time.Time{}
 			require.Equal(t, test.expected, *attrs)
 
 			actual := subject.Get(test.objectName)
+			actual.Updated = time.Time{}
 			assert.Equal(t, test.expected, *actual)
 		})
 	}
 }
 
+func TestGCS_UpdateObject_MergesMetadata(t *testing.T) {
+	subject := NewServer("b")
+	t.Cleanup(subject.Close)
+
+	client, err := subject.Client(context.Background())
+	require.NoError(t, err)
+
+	subject.Add("object", storage.ObjectAttrs{
+		Metadata:       map[string]string{"kept": "v", "changed": "v", "removed": "v"},
+		Generation:     1,
+		Metageneration: 1,
+	})
+	object := client.Bucket("b").Object("object")
+
+	// Keys missing from the update are kept, as they are by GCS
+	attrs, err := object.If(storage.Conditions{MetagenerationMatch: 1}).
+		Update(context.Background(), storage.ObjectAttrsToUpdate{Metadata: map[string]string{"changed": "w", "added": "w"}})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"kept": "v", "changed": "w", "removed": "v", "added": "w"}, attrs.Metadata)
+
+	// Keys can be cleared with an empty value, but the client has no way to remove a single key
+	attrs, err = object.If(storage.Conditions{MetagenerationMatch: 2}).
+		Update(context.Background(), storage.ObjectAttrsToUpdate{Metadata: map[string]string{"removed": ""}})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"kept": "v", "changed": "w", "removed": "", "added": "w"}, attrs.Metadata)
+
+	// Keys sent as null are removed, which is only possible with the JSON API directly
+	request := httptest.NewRequest(http.MethodPatch, "/b/b/o/object?ifMetagenerationMatch=3",
+		strings.NewReader(`{"metadata": {"removed": null}}`))
+	response := httptest.NewRecorder()
+	subject.Handler().ServeHTTP(response, request)
+	require.Equal(t, http.StatusOK, response.Code, response.Body.String())
+	assert.Equal(t, map[string]string{"kept": "v", "changed": "w", "added": "w"}, subject.Get("object").Metadata)
+
+	// Updating other fields leaves the metadata alone
+	attrs, err = object.If(storage.Conditions{MetagenerationMatch: 4}).
+		Update(context.Background(), storage.ObjectAttrsToUpdate{CacheControl: "no-store"})
+	require.NoError(t, err)
+	assert.Len(t, attrs.Metadata, 3)
+
+	// An empty map removes all of the metadata
+	attrs, err = object.If(storage.Conditions{MetagenerationMatch: 5}).
+		Update(context.Background(), storage.ObjectAttrsToUpdate{Metadata: map[string]string{}})
+	require.NoError(t, err)
+	assert.Empty(t, attrs.Metadata)
+	assert.Empty(t, subject.Get("object").Metadata)
+}
+
 func TestGCS_DeleteObject(t *testing.T) {
 	tests := []struct {
 		name          string
 		bucket        string
 		initialObject storage.ObjectAttrs
 		objectName    string
+		condition     *storage.Conditions
 		expectedError bool
 	}{
 		{
@@ -366,6 +477,51 @@ func TestGCS_DeleteObject(t *testing.T) {
 			},
 			expectedError: false,
 		},
+		{
+			name:       "deletes object with matching generations",
+			bucket:     "b",
+			objectName: "object",
+			initialObject: storage.ObjectAttrs{
+				Bucket:         "b",
+				Name:           "object",
+				CacheControl:   "no-cache",
+				Metadata:       map[string]string{"k": "v"},
+				Generation:     5,
+				Metageneration: 3,
+			},
+			condition:     &storage.Conditions{GenerationMatch: 5, MetagenerationMatch: 3},
+			expectedError: false,
+		},
+		{
+			name:       "fails to delete object if wrong generation",
+			bucket:     "b",
+			objectName: "object",
+			initialObject: storage.ObjectAttrs{
+				Bucket:         "b",
+				Name:           "object",
+				CacheControl:   "no-cache",
+				Metadata:       map[string]string{"k": "v"},
+				Generation:     5,
+				Metageneration: 3,
+			},
+			condition:     &storage.Conditions{GenerationMatch: 4},
+			expectedError: true,
+		},
+		{
+			name:       "fails to delete object if wrong metageneration",
+			bucket:     "b",
+			objectName: "object",
+			initialObject: storage.ObjectAttrs{
+				Bucket:         "b",
+				Name:           "object",
+				CacheControl:   "no-cache",
+				Metadata:       map[string]string{"k": "v"},
+				Generation:     5,
+				Metageneration: 3,
+			},
+			condition:     &storage.Conditions{MetagenerationMatch: 2},
+			expectedError: true,
+		},
 		{
 			name:          "unknown object",
 			bucket:        "b",
@@ -386,8 +542,11 @@ func TestGCS_DeleteObject(t *testing.T) {
 
 			client.SetRetry(storage.WithMaxAttempts(1))
 
-			err = client.Bucket(test.bucket).Object(test.objectName).
-				Delete(context.Background())
+			object := client.Bucket(test.bucket).Object(test.objectName)
+			if test.condition != nil {
+				object = object.If(*test.condition)
+			}
+			err = object.Delete(context.Background())
 
 			if test.expectedError {
 				assert.Error(t, err)
@@ -400,3 +559,40 @@ func TestGCS_DeleteObject(t *testing.T) {
 		})
 	}
 }
+
+func TestGCS_Generations(t *testing.T) {
+	ctx := context.Background()
+
+	subject := NewServer("b")
+	subject.Add("existing", storage.ObjectAttrs{Generation: 5, Metageneration: 1})
+	t.Cleanup(subject.Close)
+
+	client, err := subject.Client(ctx)
+	require.NoError(t, err)
+
+	client.SetRetry(storage.WithMaxAttempts(1))
+
+	create := func(conditions storage.Conditions) error {
+		return client.Bucket("b").Object("object").If(conditions).NewWriter(ctx).Close()
+	}
+
+	require.NoError(t, create(storage.Conditions{DoesNotExist: true}))
+	assert.Equal(t, int64(6), subject.Get("object").Generation, "generations should follow on from those added")
+
+	var apiErr *googleapi.Error
+	require.ErrorAs(t, create(storage.Conditions{DoesNotExist: true}), &apiErr)
+	assert.Equal(t, http.StatusPreconditionFailed, apiErr.Code)
+	require.ErrorAs(t, create(storage.Conditions{GenerationMatch: 5}), &apiErr)
+	assert.Equal(t, http.StatusPreconditionFailed, apiErr.Code)
+
+	// Replacing the object gives it a new generation, as does creating it again after it's deleted
+	require.NoError(t, create(storage.Conditions{GenerationMatch: 6}))
+	assert.Equal(t, int64(7), subject.Get("object").Generation)
+
+	require.NoError(t, client.Bucket("b").Object("object").Delete(ctx))
+	require.NoError(t, create(storage.Conditions{DoesNotExist: true}))
+	assert.Equal(t, int64(8), subject.Get("object").Generation)
+
+	err = client.Bucket("b").Object("missing").If(storage.Conditions{GenerationMatch: 1}).Delete(ctx)
+	assert.ErrorIs(t, err, storage.ErrObjectNotExist)
+}
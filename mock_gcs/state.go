@@ -0,0 +1,50 @@
+package mock_gcs // nolint:revive // Nothing wrong with underscore in a name
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	v1 "google.golang.org/api/storage/v1"
+)
+
+// state is the persisted form of the objects held by the server.
+type state struct {
+	Generation int64        `json:"generation"`
+	Objects    []*v1.Object `json:"objects"`
+}
+
+// Save writes the objects held by the server, so that they can be restored with Load.
+func (s *Server) Save(w io.Writer) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	saved := state{Generation: s.generation}
+	for _, name := range s.names() {
+		saved.Objects = append(saved.Objects, s.data[name])
+	}
+
+	if err := json.NewEncoder(w).Encode(saved); err != nil {
+		return fmt.Errorf("unable to save objects: %w", err)
+	}
+	return nil
+}
+
+// Load replaces the objects held by the server with those written by Save. Generations carry on from where they were
+// when the objects were saved.
+func (s *Server) Load(r io.Reader) error {
+	var loaded state
+	if err := json.NewDecoder(r).Decode(&loaded); err != nil {
+		return fmt.Errorf("unable to load objects: %w", err)
+	}
+
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	s.generation = loaded.Generation
+	s.data = make(map[string]*v1.Object, len(loaded.Objects))
+	for _, obj := range loaded.Objects {
+		s.data[obj.Name] = obj
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package mock_gcs // nolint:revive // Nothing wrong with underscore in a name
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_SaveLoad(t *testing.T) {
+	created := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+	original := NewServer("b")
+	original.Add("a", storage.ObjectAttrs{Metadata: map[string]string{"k": "v"}, Generation: 3, Metageneration: 2, Created: created})
+	original.Add("b/c", storage.ObjectAttrs{Generation: 7, Metageneration: 1, CacheControl: "no-store"})
+
+	var saved bytes.Buffer
+	require.NoError(t, original.Save(&saved))
+
+	subject := NewServer("b")
+	subject.Add("replaced", storage.ObjectAttrs{})
+	require.NoError(t, subject.Load(&saved))
+	t.Cleanup(subject.Close)
+
+	assert.Nil(t, subject.Get("replaced"))
+	assert.Equal(t, original.Get("a"), subject.Get("a"))
+	assert.Equal(t, original.Get("b/c"), subject.Get("b/c"))
+
+	// Generations carry on from the saved objects
+	client, err := subject.Client(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, client.Bucket("b").Object("new").NewWriter(context.Background()).Close())
+	assert.Equal(t, int64(8), subject.Get("new").Generation)
+
+	assert.ErrorContains(t, subject.Load(strings.NewReader("{")), "unable to load objects")
+}
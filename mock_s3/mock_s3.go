@@ -0,0 +1,319 @@
+package mock_s3 // nolint:revive // Nothing wrong with underscore in a name
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+const metadataHeaderPrefix = "X-Amz-Meta-"
+
+// Object is an object held by the mock server.
+type Object struct {
+	Metadata     map[string]string
+	CacheControl string
+	ETag         string
+	LastModified time.Time
+}
+
+// Server is a mock Amazon S3 server for testing, supporting the conditional writes used by the lock.
+type Server struct {
+	m      sync.Mutex
+	data   map[string]*Object
+	bucket string
+	server *httptest.Server
+	etags  int64
+
+	failOnObjectName *string
+}
+
+// Opt is a function type for configuring the mock server.
+type Opt func(*Server)
+
+// WithFailOnObjectName configures the server to fail on operations with the specified object name.
+func WithFailOnObjectName(name string) Opt {
+	return func(s *Server) {
+		s.failOnObjectName = &name
+	}
+}
+
+// NewServer creates a new mock Amazon S3 server, which serves a single bucket using path-style addressing.
+func NewServer(bucket string, opts ...Opt) *Server {
+	server := &Server{
+		m:      sync.Mutex{},
+		data:   map[string]*Object{},
+		bucket: bucket,
+	}
+
+	for _, opt := range opts {
+		opt(server)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /{bucket}", server.validateRequest(server.listObjects))
+	mux.Handle("PUT /{bucket}/{object...}", server.validateRequest(server.putObject))
+	mux.Handle("HEAD /{bucket}/{object...}", server.validateRequest(server.headObject))
+	mux.Handle("DELETE /{bucket}/{object...}", server.validateRequest(server.deleteObject))
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, 550, "NotImplemented", fmt.Sprintf("%s %s not handled", r.Method, r.URL.Path))
+	})
+	server.server = httptest.NewUnstartedServer(mux)
+	return server
+}
+
+// Close shuts down the mock server.
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// Client returns an Amazon S3 client configured to use this mock server, which is started the first time it's called.
+func (s *Server) Client(context.Context) (*s3.Client, error) {
+	if s.server.URL == "" {
+		s.server.StartTLS()
+	}
+
+	return s3.New(s3.Options{
+		BaseEndpoint: aws.String(s.server.URL),
+		Credentials:  aws.AnonymousCredentials{},
+		HTTPClient:   s.server.Client(),
+		Region:       "us-east-1",
+		UsePathStyle: true,
+	}), nil
+}
+
+// Add adds an object to the mock server's storage. An ETag is generated if one isn't provided.
+func (s *Server) Add(name string, object Object) { // nolint:gocritic
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if object.ETag == "" {
+		object.ETag = s.nextETag()
+	}
+	object.Metadata = maps.Clone(object.Metadata)
+	s.data[name] = &object
+}
+
+// Get retrieves an object from the mock server's storage.
+func (s *Server) Get(name string) *Object {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	obj, ok := s.data[name]
+	if !ok {
+		return nil
+	}
+
+	o := *obj
+	o.Metadata = maps.Clone(obj.Metadata)
+	return &o
+}
+
+// RemoveAll removes all objects from the mock server's storage.
+func (s *Server) RemoveAll() {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	s.data = map[string]*Object{}
+}
+
+func (s *Server) validateRequest(next func(http.ResponseWriter, *http.Request)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("bucket") != s.bucket {
+			writeError(w, http.StatusNotFound, "NoSuchBucket", "incorrect bucket")
+			return
+		}
+		if s.failOnObjectName != nil && r.PathValue("object") == *s.failOnObjectName {
+			writeError(w, http.StatusTeapot, "Teapot", "failed on name")
+			return
+		}
+		http.HandlerFunc(next).ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) listObjects(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("list-type") != "2" {
+		writeError(w, http.StatusNotImplemented, "NotImplemented", "only ListObjectsV2 is supported")
+		return
+	}
+	prefix := r.URL.Query().Get("prefix")
+
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	result := listBucketResult{Name: s.bucket, Prefix: prefix, MaxKeys: 1000}
+	for _, name := range s.names() {
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		result.Contents = append(result.Contents, listBucketObject{
+			Key:          name,
+			ETag:         s.data[name].ETag,
+			LastModified: s.data[name].LastModified.Format(time.RFC3339),
+		})
+	}
+	result.KeyCount = len(result.Contents)
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusOK)
+	if err := xml.NewEncoder(w).Encode(result); err != nil {
+		panic(err)
+	}
+}
+
+func (s *Server) putObject(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("object")
+
+	// The content isn't stored, only the metadata
+	defer func() {
+		_ = r.Body.Close()
+	}()
+	if _, err := io.Copy(io.Discard, r.Body); err != nil {
+		writeError(w, http.StatusBadRequest, "IncompleteBody", err.Error())
+		return
+	}
+
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	existing, exists := s.data[name]
+	if r.Header.Get("If-None-Match") == "*" && exists {
+		writeError(w, http.StatusPreconditionFailed, "PreconditionFailed", "putObject already has that object")
+		return
+	}
+	if ifMatch := r.Header.Get("If-Match"); ifMatch != "" {
+		if !exists {
+			writeError(w, http.StatusNotFound, "NoSuchKey", "putObject missing object")
+			return
+		}
+		if ifMatch != existing.ETag {
+			writeError(w, http.StatusPreconditionFailed, "PreconditionFailed", "putObject with old ETag")
+			return
+		}
+	}
+
+	metadata := map[string]string{}
+	for key, values := range r.Header {
+		if strings.HasPrefix(key, metadataHeaderPrefix) {
+			metadata[strings.ToLower(strings.TrimPrefix(key, metadataHeaderPrefix))] = values[0]
+		}
+	}
+
+	object := &Object{
+		Metadata:     metadata,
+		CacheControl: r.Header.Get("Cache-Control"),
+		ETag:         s.nextETag(),
+		LastModified: time.Now().UTC(),
+	}
+	s.data[name] = object
+
+	w.Header().Set("ETag", object.ETag)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) headObject(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("object")
+
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	obj, ok := s.data[name]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	for key, value := range obj.Metadata {
+		w.Header().Set(metadataHeaderPrefix+key, value)
+	}
+	if obj.CacheControl != "" {
+		w.Header().Set("Cache-Control", obj.CacheControl)
+	}
+	if !obj.LastModified.IsZero() {
+		w.Header().Set("Last-Modified", obj.LastModified.Format(http.TimeFormat))
+	}
+	w.Header().Set("ETag", obj.ETag)
+	w.Header().Set("Content-Length", "0")
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) deleteObject(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("object")
+
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	obj, ok := s.data[name]
+	if ifMatch := r.Header.Get("If-Match"); ifMatch != "" {
+		if !ok {
+			writeError(w, http.StatusNotFound, "NoSuchKey", "deleteObject missing object")
+			return
+		}
+		if ifMatch != obj.ETag {
+			writeError(w, http.StatusPreconditionFailed, "PreconditionFailed", "deleteObject with old ETag")
+			return
+		}
+	}
+
+	delete(s.data, name)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// names returns the names of all objects in order, and must be called while holding the lock.
+func (s *Server) names() []string {
+	names := make([]string, 0, len(s.data))
+	for name := range s.data {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	return names
+}
+
+func (s *Server) nextETag() string {
+	s.etags++
+	return strconv.Quote(strconv.FormatInt(s.etags, 16))
+}
+
+type listBucketResult struct {
+	XMLName     xml.Name           `xml:"ListBucketResult"`
+	Name        string             `xml:"Name"`
+	Prefix      string             `xml:"Prefix"`
+	KeyCount    int                `xml:"KeyCount"`
+	MaxKeys     int                `xml:"MaxKeys"`
+	IsTruncated bool               `xml:"IsTruncated"`
+	Contents    []listBucketObject `xml:"Contents"`
+}
+
+type listBucketObject struct {
+	Key          string `xml:"Key"`
+	ETag         string `xml:"ETag"`
+	LastModified string `xml:"LastModified"`
+	Size         int64  `xml:"Size"`
+}
+
+type errorResponse struct {
+	XMLName xml.Name `xml:"Error"`
+	Code    string   `xml:"Code"`
+	Message string   `xml:"Message"`
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	if err := xml.NewEncoder(w).Encode(errorResponse{Code: code, Message: message}); err != nil {
+		panic(err)
+	}
+}
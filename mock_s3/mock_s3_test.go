@@ -0,0 +1,370 @@
+package mock_s3 // nolint:revive // Nothing wrong with underscore in a name
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_RemoveAll(t *testing.T) {
+	subject := NewServer("b")
+	subject.Add("b", Object{})
+	subject.RemoveAll()
+
+	assert.Empty(t, subject.data)
+}
+
+func TestServer_Client(t *testing.T) {
+	subject := NewServer("b")
+	t.Cleanup(subject.Close)
+	subject.Add("object", Object{})
+
+	// Clients can be created more than once, all of them using the same server
+	for range 2 {
+		client, err := subject.Client(context.Background())
+		require.NoError(t, err)
+
+		_, err = client.HeadObject(context.Background(), &s3.HeadObjectInput{Bucket: aws.String("b"), Key: aws.String("object")})
+		require.NoError(t, err)
+	}
+}
+
+func TestS3_ListObjects(t *testing.T) {
+	tests := []struct {
+		name           string
+		bucket         string
+		prefix         string
+		expectedStatus int
+		expected       []string
+	}{
+		{
+			name:           "validates bucket",
+			bucket:         "different",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:     "lists all objects",
+			bucket:   "b",
+			expected: []string{"a/1", "a/2", "b"},
+		},
+		{
+			name:     "lists objects with prefix",
+			bucket:   "b",
+			prefix:   "a/",
+			expected: []string{"a/1", "a/2"},
+		},
+		{
+			name:   "lists nothing without matches",
+			bucket: "b",
+			prefix: "c",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			subject := NewServer("b")
+			subject.Add("b", Object{})
+			subject.Add("a/2", Object{})
+			subject.Add("a/1", Object{})
+			t.Cleanup(subject.Close)
+
+			client, err := subject.Client(context.Background())
+			require.NoError(t, err)
+
+			out, err := client.ListObjectsV2(context.Background(), &s3.ListObjectsV2Input{
+				Bucket: aws.String(test.bucket),
+				Prefix: aws.String(test.prefix),
+			}, withNoRetries)
+
+			if test.expectedStatus != 0 {
+				assertStatus(t, err, test.expectedStatus)
+				return
+			}
+			require.NoError(t, err)
+
+			var keys []string
+			for _, object := range out.Contents {
+				keys = append(keys, aws.ToString(object.Key))
+			}
+			assert.Equal(t, test.expected, keys)
+			assert.Equal(t, int32(len(test.expected)), aws.ToInt32(out.KeyCount))
+		})
+	}
+}
+
+func TestS3_PutObject(t *testing.T) {
+	tests := []struct {
+		name           string
+		bucket         string
+		initialObject  *Object
+		ifNoneMatch    *string
+		ifMatch        *string
+		expectedStatus int
+		expected       *Object
+	}{
+		{
+			name:           "validates bucket",
+			bucket:         "different",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:   "saves object",
+			bucket: "b",
+			expected: &Object{
+				Metadata:     map[string]string{"k": "v"},
+				CacheControl: "no-store",
+			},
+		},
+		{
+			name:          "overwrites object",
+			bucket:        "b",
+			initialObject: &Object{Metadata: map[string]string{"k": "old"}},
+			expected: &Object{
+				Metadata:     map[string]string{"k": "v"},
+				CacheControl: "no-store",
+			},
+		},
+		{
+			name:          "creates object if none match",
+			bucket:        "b",
+			initialObject: nil,
+			ifNoneMatch:   aws.String("*"),
+			expected: &Object{
+				Metadata:     map[string]string{"k": "v"},
+				CacheControl: "no-store",
+			},
+		},
+		{
+			name:           "fails to create existing object if none match",
+			bucket:         "b",
+			initialObject:  &Object{ETag: `"old"`, Metadata: map[string]string{"k": "old"}},
+			ifNoneMatch:    aws.String("*"),
+			expectedStatus: http.StatusPreconditionFailed,
+			expected:       &Object{ETag: `"old"`, Metadata: map[string]string{"k": "old"}},
+		},
+		{
+			name:          "updates object if ETag matches",
+			bucket:        "b",
+			initialObject: &Object{ETag: `"old"`, Metadata: map[string]string{"k": "old"}},
+			ifMatch:       aws.String(`"old"`),
+			expected: &Object{
+				Metadata:     map[string]string{"k": "v"},
+				CacheControl: "no-store",
+			},
+		},
+		{
+			name:           "fails to update object with old ETag",
+			bucket:         "b",
+			initialObject:  &Object{ETag: `"new"`, Metadata: map[string]string{"k": "old"}},
+			ifMatch:        aws.String(`"old"`),
+			expectedStatus: http.StatusPreconditionFailed,
+			expected:       &Object{ETag: `"new"`, Metadata: map[string]string{"k": "old"}},
+		},
+		{
+			name:           "fails to update missing object",
+			bucket:         "b",
+			ifMatch:        aws.String(`"old"`),
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			subject := NewServer("b")
+			if test.initialObject != nil {
+				subject.Add("object", *test.initialObject)
+			}
+			t.Cleanup(subject.Close)
+
+			client, err := subject.Client(context.Background())
+			require.NoError(t, err)
+
+			_, err = client.PutObject(context.Background(), &s3.PutObjectInput{
+				Bucket:       aws.String(test.bucket),
+				Key:          aws.String("object"),
+				Body:         strings.NewReader("content"),
+				CacheControl: aws.String("no-store"),
+				IfMatch:      test.ifMatch,
+				IfNoneMatch:  test.ifNoneMatch,
+				Metadata:     map[string]string{"k": "v"},
+			}, withNoRetries)
+
+			if test.expectedStatus != 0 {
+				assertStatus(t, err, test.expectedStatus)
+			} else {
+				require.NoError(t, err)
+			}
+
+			actual := subject.Get("object")
+			if test.expected == nil {
+				assert.Nil(t, actual)
+				return
+			}
+
+			require.NotNil(t, actual)
+			if test.expected.ETag == "" {
+				assert.NotEmpty(t, actual.ETag)
+				actual.ETag = ""
+			}
+			actual.LastModified = test.expected.LastModified
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
+
+func TestS3_HeadObject(t *testing.T) {
+	tests := []struct {
+		name           string
+		bucket         string
+		objectName     string
+		expectedStatus int
+	}{
+		{
+			name:           "validates bucket",
+			bucket:         "different",
+			objectName:     "object",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:       "reads object",
+			bucket:     "b",
+			objectName: "object",
+		},
+		{
+			name:           "unknown object",
+			bucket:         "b",
+			objectName:     "missing",
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			subject := NewServer("b")
+			subject.Add("object", Object{
+				Metadata:     map[string]string{"k": "v"},
+				CacheControl: "no-cache",
+				ETag:         `"etag"`,
+			})
+			t.Cleanup(subject.Close)
+
+			client, err := subject.Client(context.Background())
+			require.NoError(t, err)
+
+			out, err := client.HeadObject(context.Background(), &s3.HeadObjectInput{
+				Bucket: aws.String(test.bucket),
+				Key:    aws.String(test.objectName),
+			}, withNoRetries)
+
+			if test.expectedStatus != 0 {
+				assertStatus(t, err, test.expectedStatus)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, map[string]string{"k": "v"}, out.Metadata)
+			assert.Equal(t, "no-cache", aws.ToString(out.CacheControl))
+			assert.Equal(t, `"etag"`, aws.ToString(out.ETag))
+		})
+	}
+}
+
+func TestS3_DeleteObject(t *testing.T) {
+	tests := []struct {
+		name           string
+		bucket         string
+		objectName     string
+		ifMatch        *string
+		expectedStatus int
+		expectRemain   bool
+	}{
+		{
+			name:           "validates bucket",
+			bucket:         "different",
+			objectName:     "object",
+			expectedStatus: http.StatusNotFound,
+			expectRemain:   true,
+		},
+		{
+			name:       "deletes object",
+			bucket:     "b",
+			objectName: "object",
+		},
+		{
+			name:       "deletes object if ETag matches",
+			bucket:     "b",
+			objectName: "object",
+			ifMatch:    aws.String(`"etag"`),
+		},
+		{
+			name:           "fails to delete object with old ETag",
+			bucket:         "b",
+			objectName:     "object",
+			ifMatch:        aws.String(`"old"`),
+			expectedStatus: http.StatusPreconditionFailed,
+			expectRemain:   true,
+		},
+		{
+			name:         "unknown object",
+			bucket:       "b",
+			objectName:   "missing",
+			expectRemain: true,
+		},
+		{
+			name:           "unknown object if ETag matches",
+			bucket:         "b",
+			objectName:     "missing",
+			ifMatch:        aws.String(`"etag"`),
+			expectedStatus: http.StatusNotFound,
+			expectRemain:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			subject := NewServer("b")
+			subject.Add("object", Object{ETag: `"etag"`})
+			t.Cleanup(subject.Close)
+
+			client, err := subject.Client(context.Background())
+			require.NoError(t, err)
+
+			_, err = client.DeleteObject(context.Background(), &s3.DeleteObjectInput{
+				Bucket:  aws.String(test.bucket),
+				Key:     aws.String(test.objectName),
+				IfMatch: test.ifMatch,
+			}, withNoRetries)
+
+			if test.expectedStatus != 0 {
+				assertStatus(t, err, test.expectedStatus)
+			} else {
+				require.NoError(t, err)
+			}
+
+			if test.expectRemain {
+				assert.NotNil(t, subject.Get("object"))
+			} else {
+				assert.Nil(t, subject.Get("object"))
+			}
+		})
+	}
+}
+
+func withNoRetries(o *s3.Options) {
+	o.RetryMaxAttempts = 1
+}
+
+func assertStatus(t *testing.T, err error, status int) {
+	t.Helper()
+
+	var respErr *awshttp.ResponseError
+	require.ErrorAs(t, err, &respErr)
+	assert.Equal(t, status, respErr.HTTPStatusCode())
+}
@@ -0,0 +1,214 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"sync"
+	"time"
+)
+
+const (
+	defaultTTL                = 5 * time.Minute
+	defaultRetryInterval      = 100 * time.Millisecond
+	defaultMaxRefreshFailures = 3
+	defaultCacheControl       = "no-store"
+)
+
+// Option configures optional behaviour of a Lock.
+type Option func(*Lock)
+
+// WithTTL sets how long the lock is held for without being refreshed, before others can take it. Defaults to 5 minutes.
+func WithTTL(ttl time.Duration) Option {
+	return func(l *Lock) {
+		l.ttl = ttl
+	}
+}
+
+// WithLogger sets how the logger is retrieved from a context. By default, nothing is logged.
+func WithLogger(logger func(ctx context.Context) Logger) Option {
+	return func(l *Lock) {
+		if logger == nil {
+			logger = nopLoggerFor
+		}
+		l.logger = logger
+	}
+}
+
+// WithRetryInterval makes Lock wait the same interval between attempts to acquire the lock. Defaults to 100ms.
+func WithRetryInterval(interval time.Duration) Option {
+	return WithBackoff(ConstantBackoff(interval))
+}
+
+// WithBackoff sets how long Lock waits between attempts to acquire the lock, such as a DecorrelatedJitterBackoff when
+// many clients contend for the same lock.
+func WithBackoff(backoff Backoff) Option {
+	return func(l *Lock) {
+		l.backoff = backoff
+	}
+}
+
+// WithRefreshInterval sets how often the lock is refreshed by KeepAlive and LockContext when no interval is given. By
+// default, it's derived from the TTL and the maximum number of refresh failures.
+func WithRefreshInterval(interval time.Duration) Option {
+	return func(l *Lock) {
+		l.refreshInterval = interval
+	}
+}
+
+// WithMaxRefreshFailures sets how many consecutive times RefreshLock can fail before the lock is abandoned. Defaults
+// to 3.
+func WithMaxRefreshFailures(failures uint) Option {
+	return func(l *Lock) {
+		l.maxRefreshFailures = failures
+	}
+}
+
+// WithMaxClockSkew allows for the clocks of clients differing from the clock of the Backend by up to the given amount,
+// by waiting that much longer before treating someone else's lock as expired. Defaults to zero, as someone else's lock
+// must also go unchanged for its TTL from when it was first seen, by the local clock alone, before it's treated as
+// expired, so a client whose clock runs ahead can't take over a lock early. Setting it only delays taking over locks
+// which have expired by the Backend's update time, or the expiry written by the holder where that isn't reported.
+func WithMaxClockSkew(skew time.Duration) Option {
+	return func(l *Lock) {
+		l.maxClockSkew = skew
+	}
+}
+
+// WithCacheControl sets the cache control directive stored with the lock object. Defaults to "no-store".
+func WithCacheControl(cacheControl string) Option {
+	return func(l *Lock) {
+		l.cacheControl = cacheControl
+	}
+}
+
+// WithMetadata adds extra metadata to the lock object, such as details of the process holding it. The keys used by the
+// lock itself can't be overridden.
+func WithMetadata(metadata map[string]string) Option {
+	return func(l *Lock) {
+		l.extraMetadata = maps.Clone(metadata)
+	}
+}
+
+// WithFairness makes Lock wait in a queue, so that the lock is acquired in the order that clients started waiting for
+// it rather than by whoever happens to retry first. Every client of the lock must use this option, as the queue is
+// ignored otherwise.
+func WithFairness() Option {
+	return func(l *Lock) {
+		l.fair = true
+	}
+}
+
+// WithResume makes Lock and TryLock adopt an existing lock held by the same identity, carrying on from where it was
+// left rather than removing it and racing to take it again. This suits identities which are stable across restarts,
+// as long as two clients never share an identity.
+func WithResume() Option {
+	return func(l *Lock) {
+		l.resume = true
+	}
+}
+
+// WithClock sets the Clock used to tell the time and to schedule waits, such as between attempts to acquire the lock and
+// between refreshes, so that tests can control the passing of time. By default, the system time is used. A Backend
+// which records update times should be given the same clock, such as with NewFilesystemBackendWithClock or the
+// WithClock option of mock_gcs, otherwise locks expire by the system time.
+func WithClock(clock Clock) Option {
+	return func(l *Lock) {
+		if clock == nil {
+			clock = realClock{}
+		}
+		l.clock = clock
+	}
+}
+
+// New creates a new distributed lock instance backed by the given Backend, returning an error if the configuration
+// isn't valid.
+func New(backend Backend, identity, path string, opts ...Option) (*Lock, error) {
+	l := newLock(backend, identity, path, opts...)
+	if err := l.validate(); err != nil {
+		return nil, fmt.Errorf("invalid lock configuration: %w", err)
+	}
+
+	return l, nil
+}
+
+func newLock(backend Backend, identity, path string, opts ...Option) *Lock {
+	l := &Lock{
+		backend:                  backend,
+		path:                     path,
+		identity:                 identity,
+		ttl:                      defaultTTL,
+		logger:                   nopLoggerFor,
+		clock:                    realClock{},
+		backoff:                  ConstantBackoff(defaultRetryInterval),
+		refreshInterval:          0,
+		maxRefreshFailures:       defaultMaxRefreshFailures,
+		maxClockSkew:             0,
+		cacheControl:             defaultCacheControl,
+		observed:                 &observations{},
+		mutex:                    sync.Mutex{},
+		refreshMetadata:          false,
+		latestMetadataGeneration: 0,
+		opts:                     opts,
+	}
+
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	return l
+}
+
+func (l *Lock) validate() error {
+	var errs []error
+
+	if l.backend == nil {
+		errs = append(errs, errors.New("backend must be provided"))
+	}
+	if l.identity == "" {
+		errs = append(errs, errors.New("identity must not be empty"))
+	}
+	if l.path == "" {
+		errs = append(errs, errors.New("path must not be empty"))
+	}
+	if l.ttl <= 0 {
+		errs = append(errs, errors.New("TTL must be positive"))
+	}
+	if l.backoff == nil {
+		errs = append(errs, errors.New("backoff must be provided"))
+	} else if v, ok := l.backoff.(interface{ validate() error }); ok {
+		if err := v.validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if l.refreshInterval < 0 {
+		errs = append(errs, errors.New("refresh interval must not be negative"))
+	}
+	if l.maxClockSkew < 0 {
+		errs = append(errs, errors.New("maximum clock skew must not be negative"))
+	}
+	if budget := l.defaultRefreshInterval() * time.Duration(l.maxRefreshFailures); l.ttl > 0 && budget >= l.ttl {
+		errs = append(errs, fmt.Errorf("TTL of %s must exceed the refresh interval multiplied by the maximum refresh failures, %s",
+			l.ttl, budget))
+	}
+	for _, key := range []string{ownerMetadata, expiresAtMetadata, ttlMetadata, handoffMetadata} {
+		if _, ok := l.extraMetadata[key]; ok {
+			errs = append(errs, fmt.Errorf("metadata must not contain the reserved key %q", key))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+var _ Logger = nopLogger{}
+
+type nopLogger struct{}
+
+func (nopLogger) Info(string, ...any) {}
+
+func (nopLogger) Error(error, string, ...any) {}
+
+func nopLoggerFor(context.Context) Logger {
+	return nopLogger{}
+}
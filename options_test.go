@@ -0,0 +1,159 @@
+package lock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thg-ice/distributed-lock/mock_clock"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name        string
+		backend     Backend
+		identity    string
+		path        string
+		opts        []Option
+		expectedErr []string
+	}{
+		{
+			name:     "valid-with-defaults",
+			backend:  newMemoryBackend(),
+			identity: "me",
+			path:     "path/to/file.lock",
+		},
+		{
+			name:     "valid-with-options",
+			backend:  newMemoryBackend(),
+			identity: "me",
+			path:     "path/to/file.lock",
+			opts: []Option{
+				WithTTL(time.Minute),
+				WithRetryInterval(time.Second),
+				WithRefreshInterval(10 * time.Second),
+				WithMaxRefreshFailures(5),
+				WithMaxClockSkew(time.Second),
+				WithCacheControl("private"),
+				WithMetadata(map[string]string{"host": "example"}),
+			},
+		},
+		{
+			name: "missing-everything",
+			expectedErr: []string{
+				"backend must be provided",
+				"identity must not be empty",
+				"path must not be empty",
+			},
+		},
+		{
+			name:     "invalid-durations",
+			backend:  newMemoryBackend(),
+			identity: "me",
+			path:     "path/to/file.lock",
+			opts: []Option{
+				WithTTL(0),
+				WithRetryInterval(-time.Second),
+				WithRefreshInterval(-time.Second),
+				WithMaxClockSkew(-time.Second),
+			},
+			expectedErr: []string{
+				"TTL must be positive",
+				"retry interval must be positive",
+				"refresh interval must not be negative",
+				"maximum clock skew must not be negative",
+			},
+		},
+		{
+			name:        "missing-backoff",
+			backend:     newMemoryBackend(),
+			identity:    "me",
+			path:        "path/to/file.lock",
+			opts:        []Option{WithBackoff(nil)},
+			expectedErr: []string{"backoff must be provided"},
+		},
+		{
+			name:     "invalid-backoff",
+			backend:  newMemoryBackend(),
+			identity: "me",
+			path:     "path/to/file.lock",
+			opts:     []Option{WithBackoff(DecorrelatedJitterBackoff(0, -time.Second))},
+			expectedErr: []string{
+				"backoff base must be positive",
+				"backoff maximum must not be less than the base",
+			},
+		},
+		{
+			name:     "refresh-failures-outlast-ttl",
+			backend:  newMemoryBackend(),
+			identity: "me",
+			path:     "path/to/file.lock",
+			opts: []Option{
+				WithTTL(time.Minute),
+				WithRefreshInterval(20 * time.Second),
+				WithMaxRefreshFailures(3),
+			},
+			expectedErr: []string{"TTL of 1m0s must exceed the refresh interval multiplied by the maximum refresh failures, 1m0s"},
+		},
+		{
+			name:        "reserved-metadata",
+			backend:     newMemoryBackend(),
+			identity:    "me",
+			path:        "path/to/file.lock",
+			opts:        []Option{WithMetadata(map[string]string{ownerMetadata: "someone-else"})},
+			expectedErr: []string{`metadata must not contain the reserved key "owner"`},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			l, err := New(test.backend, test.identity, test.path, test.opts...)
+			if len(test.expectedErr) == 0 {
+				require.NoError(t, err)
+				assert.NotNil(t, l)
+				return
+			}
+
+			require.Error(t, err)
+			assert.Nil(t, l)
+			for _, expected := range test.expectedErr {
+				assert.ErrorContains(t, err, expected)
+			}
+		})
+	}
+}
+
+func TestNew_AppliesOptions(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	t.Cleanup(cancel)
+
+	clock := mock_clock.NewClock(time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC))
+
+	backend := newMemoryBackend()
+	l, err := New(backend, "me", "path/to/file.lock",
+		WithTTL(time.Minute),
+		WithClock(clock),
+		WithLogger(func(context.Context) Logger {
+			return loggerToTestingT{t}
+		}),
+		WithCacheControl("private"),
+		WithMetadata(map[string]string{"host": "example"}),
+	)
+	require.NoError(t, err)
+
+	require.NoError(t, l.Lock(ctx, time.Second))
+	attrs, err := backend.Attrs(ctx, "path/to/file.lock")
+	require.NoError(t, err)
+	assert.Equal(t, "private", attrs.CacheControl)
+	assert.Equal(t, "example", attrs.Metadata["host"])
+	assert.Equal(t, "me", attrs.Metadata[ownerMetadata])
+	assert.Equal(t, "1m0s", attrs.Metadata[ttlMetadata])
+	assert.Equal(t, "2024-03-01T12:01:00Z", attrs.Metadata[expiresAtMetadata])
+	assert.Equal(t, 12*time.Second, l.defaultRefreshInterval())
+
+	require.NoError(t, l.Unlock(ctx))
+
+	assert.Equal(t, realClock{}, newLock(backend, "me", "path", WithClock(nil)).clock)
+}
@@ -0,0 +1,267 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	rwLockWriterPath  = "writer"
+	rwLockReadersPath = "readers/"
+)
+
+var errRWLockHeld = errors.New("lock is already held in the other mode")
+
+// RWLock allows many readers or a single writer to hold a lock on a prefix at once. Each reader holds a lease object
+// under the prefix, while a writer holds an exclusive intent object which stops new readers, and then waits for the
+// existing readers to release their leases or for them to expire. Leases and intents are held in the same way as a
+// Lock with the same options, including TTL expiry and the removal of stale objects. As with a Lock, the identity must
+// be unique to each holder.
+type RWLock struct {
+	backend  Backend
+	identity string
+	prefix   string
+	opts     []Option
+
+	reader *Lock
+	writer *Lock
+
+	mutex  sync.Mutex
+	held   *Lock
+	leases observations
+}
+
+// NewRWLock creates a read/write lock using objects under the prefix in the Backend. The options are applied to the
+// Lock for each object.
+func NewRWLock(backend Backend, identity, prefix string, opts ...Option) (*RWLock, error) {
+	if prefix == "" {
+		return nil, errors.New("invalid lock configuration: prefix must not be empty")
+	}
+	prefix = strings.TrimSuffix(prefix, "/") + "/"
+
+	reader, err := New(backend, identity, prefix+rwLockReadersPath+identity, opts...)
+	if err != nil {
+		return nil, err
+	}
+	writer, err := New(backend, identity, prefix+rwLockWriterPath, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RWLock{
+		backend:  backend,
+		identity: identity,
+		prefix:   prefix,
+		opts:     opts,
+		reader:   reader,
+		writer:   writer,
+	}, nil
+}
+
+// RLock will attempt to acquire the lock for reading until the context has timed out, waiting as decided by the
+// configured Backoff while a writer holds or is waiting for the lock.
+func (l *RWLock) RLock(ctx context.Context, timeout time.Duration) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.held == l.reader {
+		return nil
+	} else if l.held != nil {
+		return errRWLockHeld
+	}
+
+	err := retry(ctx, l.reader.clock, timeout, l.reader.backoff, l.tryRLock)
+	if err == nil {
+		l.held = l.reader
+	}
+	return err
+}
+
+// tryRLock takes out a lease as long as there's no writer. The writer is checked again after taking the lease, in case
+// a writer arrived in the meantime, as it will be waiting for this lease to be released.
+func (l *RWLock) tryRLock(ctx context.Context) error {
+	if err := l.checkNoWriter(ctx); err != nil {
+		return err
+	}
+
+	acquired, holder, err := l.reader.TryLock(ctx)
+	if err != nil {
+		return err
+	}
+	if !acquired {
+		return &LockHeldError{Holder: *holder}
+	}
+
+	if err := l.checkNoWriter(ctx); err != nil {
+		return errors.Join(err, l.reader.Unlock(ctx))
+	}
+
+	return nil
+}
+
+// checkNoWriter returns a LockHeldError if a writer holds the lock, removing it first if it's stale.
+func (l *RWLock) checkNoWriter(ctx context.Context) error {
+	holder, err := l.writer.deleteLockIfStale(ctx)
+	if errors.Is(err, ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if holder != nil {
+		return &LockHeldError{Holder: *holder}
+	}
+
+	return nil
+}
+
+// RUnlock will release the lock held for reading. Any refresher started by KeepAlive is stopped first.
+func (l *RWLock) RUnlock(ctx context.Context) error {
+	return l.unlock(ctx, l.reader)
+}
+
+// Lock will attempt to acquire the lock for writing until the context has timed out. Once no other writer holds the
+// lock, new readers are stopped and the existing readers waited for, with the writer's intent refreshed while waiting.
+// The configured Backoff decides how long to wait between attempts.
+func (l *RWLock) Lock(ctx context.Context, timeout time.Duration) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.held == l.writer {
+		return nil
+	} else if l.held != nil {
+		return errRWLockHeld
+	}
+
+	intent := false
+	err := retry(ctx, l.writer.clock, timeout, l.writer.backoff, func(ctx context.Context) error {
+		return l.tryLock(ctx, &intent)
+	})
+	if err != nil && intent {
+		// Stop blocking readers if they couldn't be waited for
+		return errors.Join(err, l.writer.Unlock(context.WithoutCancel(ctx)))
+	} else if err != nil {
+		return err
+	}
+
+	l.held = l.writer
+	return nil
+}
+
+// tryLock takes out the writer's intent if it isn't already held, otherwise it's refreshed, and then checks that there
+// are no readers left.
+func (l *RWLock) tryLock(ctx context.Context, intent *bool) error {
+	if *intent {
+		if err := l.writer.RefreshLock(ctx); err != nil {
+			*intent = false
+			return err
+		}
+	} else {
+		acquired, holder, err := l.writer.TryLock(ctx)
+		if err != nil {
+			return err
+		}
+		if !acquired {
+			return &LockHeldError{Holder: *holder}
+		}
+		*intent = true
+	}
+
+	return l.checkNoReaders(ctx)
+}
+
+// checkNoReaders returns a LockHeldError if any reader holds the lock, removing stale leases.
+func (l *RWLock) checkNoReaders(ctx context.Context) error {
+	paths, err := l.backend.List(ctx, l.prefix+rwLockReadersPath)
+	if err != nil {
+		return err
+	}
+
+	l.leases.retain(l.prefix+rwLockReadersPath, paths)
+
+	for _, path := range paths {
+		// Use a Lock for the reader's lease so that it's treated in the same way as any other lock
+		lease := newLock(l.backend, l.identity, path, l.opts...)
+		lease.observed = &l.leases
+		holder, err := lease.deleteLockIfStale(ctx)
+		if err != nil && !errors.Is(err, ErrNotExist) {
+			return err
+		}
+		if holder != nil {
+			return &LockHeldError{Holder: *holder}
+		}
+	}
+
+	return nil
+}
+
+// Unlock will release the lock held for writing. Any refresher started by KeepAlive is stopped first.
+func (l *RWLock) Unlock(ctx context.Context) error {
+	return l.unlock(ctx, l.writer)
+}
+
+func (l *RWLock) unlock(ctx context.Context, lock *Lock) error {
+	// The refresher takes the mutex if the lock is lost, so it's stopped before the mutex is held
+	lock.stopRefreshing()
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.held != lock {
+		return nil
+	}
+
+	l.held = nil
+	return lock.Unlock(ctx)
+}
+
+// RefreshLock will update the lease or intent held, in the same way as Lock.RefreshLock. Once the lease or intent has
+// been lost, the lock is no longer treated as held, so that it can be taken again in either mode.
+func (l *RWLock) RefreshLock(ctx context.Context) error {
+	held := l.heldLock()
+	if held == nil {
+		return nil
+	}
+
+	err := held.RefreshLock(ctx)
+	if errors.Is(err, ErrLockAbandoned) {
+		l.lost(held)
+	}
+	return err
+}
+
+// KeepAlive starts a background refresher for the lease or intent held, in the same way as Lock.KeepAlive. If the lock
+// isn't held, ErrLockAbandoned is sent on the returned channel straight away. As with RefreshLock, a lost lease or
+// intent is no longer held.
+func (l *RWLock) KeepAlive(ctx context.Context, interval time.Duration) <-chan error {
+	held := l.heldLock()
+	if held == nil {
+		lost := make(chan error, 1)
+		lost <- ErrLockAbandoned
+		close(lost)
+		return lost
+	}
+
+	return held.keepAlive(ctx, interval, nil, func() {
+		l.lost(held)
+	})
+}
+
+func (l *RWLock) heldLock() *Lock {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return l.held
+}
+
+// lost stops treating the lease or intent as held, unless the lock has been taken again since.
+func (l *RWLock) lost(lock *Lock) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.held == lock {
+		l.held = nil
+	}
+}
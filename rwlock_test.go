@@ -0,0 +1,123 @@
+package lock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewRWLock(t *testing.T) {
+	_, err := NewRWLock(newMemoryBackend(), "id", "")
+	assert.ErrorContains(t, err, "prefix must not be empty")
+
+	_, err = NewRWLock(newMemoryBackend(), "", "dataset")
+	assert.ErrorContains(t, err, "identity must not be empty")
+
+	l, err := NewRWLock(newMemoryBackend(), "id", "dataset/")
+	require.NoError(t, err)
+	assert.Equal(t, "dataset/readers/id", l.reader.path)
+	assert.Equal(t, "dataset/writer", l.writer.path)
+}
+
+func TestRWLock(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	t.Cleanup(cancel)
+
+	backend := newMemoryBackend()
+	newRWLock := func(identity string, ttl time.Duration) *RWLock {
+		l, err := NewRWLock(backend, identity, "dataset", WithTTL(ttl), WithRetryInterval(10*time.Millisecond),
+			WithLogger(func(context.Context) Logger {
+				return loggerToTestingT{t}
+			}))
+		require.NoError(t, err)
+		return l
+	}
+	first, second, writer := newRWLock("first", time.Minute), newRWLock("second", time.Minute), newRWLock("writer", time.Minute)
+
+	// Readers share the lock, and keep the writer out
+	require.NoError(t, first.RLock(ctx, time.Second))
+	require.NoError(t, second.RLock(ctx, time.Second))
+	err := writer.Lock(ctx, 100*time.Millisecond)
+	assert.ErrorIs(t, err, ErrTimeout)
+	assert.ErrorIs(t, err, ErrLockHeld)
+	assert.NotContains(t, backend.objects, "dataset/writer", "the writer should stop blocking readers when it gives up")
+
+	// Holding the lock in one mode stops it being taken in the other
+	assert.ErrorIs(t, first.Lock(ctx, time.Second), errRWLockHeld)
+	require.NoError(t, first.RLock(ctx, time.Second))
+
+	// A waiting writer blocks new readers, and gets the lock once the existing readers have gone
+	require.NoError(t, second.RUnlock(ctx))
+	acquired := make(chan error)
+	go func() {
+		acquired <- writer.Lock(ctx, 10*time.Second)
+	}()
+	require.Eventually(t, func() bool {
+		info, err := Inspect(ctx, backend, "dataset/writer")
+		return err == nil && info != nil
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.ErrorIs(t, second.RLock(ctx, 100*time.Millisecond), ErrLockHeld)
+
+	require.NoError(t, first.RUnlock(ctx))
+	require.NoError(t, <-acquired)
+	require.NoError(t, writer.RefreshLock(ctx))
+
+	// Writers are exclusive
+	other := newRWLock("other-writer", time.Minute)
+	assert.ErrorIs(t, other.Lock(ctx, 100*time.Millisecond), ErrLockHeld)
+	assert.ErrorIs(t, first.RLock(ctx, 100*time.Millisecond), ErrLockHeld)
+
+	require.NoError(t, writer.Unlock(ctx))
+	require.NoError(t, first.RLock(ctx, time.Second))
+	require.NoError(t, first.RUnlock(ctx))
+	assert.Empty(t, backend.objects)
+}
+
+func TestRWLock_RemovesStaleReaders(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	t.Cleanup(cancel)
+
+	backend := newMemoryBackend()
+	reader, err := NewRWLock(backend, "reader", "dataset", WithTTL(50*time.Millisecond))
+	require.NoError(t, err)
+	writer, err := NewRWLock(backend, "writer", "dataset", WithTTL(time.Minute), WithRetryInterval(10*time.Millisecond))
+	require.NoError(t, err)
+
+	require.NoError(t, reader.RLock(ctx, time.Second))
+	require.NoError(t, writer.Lock(ctx, 5*time.Second))
+	assert.NotContains(t, backend.objects, "dataset/readers/reader")
+
+	assert.ErrorIs(t, reader.RefreshLock(ctx), ErrLockAbandoned)
+}
+
+func TestRWLock_Lost(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	t.Cleanup(cancel)
+
+	backend := newMemoryBackend()
+	l, err := NewRWLock(backend, "id", "dataset", WithTTL(time.Minute))
+	require.NoError(t, err)
+
+	// A lease lost when refreshing is taken again by the next attempt, rather than it being treated as still held
+	require.NoError(t, l.RLock(ctx, time.Second))
+	_, err = ForceRelease(ctx, backend, "dataset/readers/id", "admin", "wedged")
+	require.NoError(t, err)
+	assert.ErrorIs(t, l.RefreshLock(ctx), ErrLockAbandoned)
+
+	require.NoError(t, l.RLock(ctx, time.Second))
+	assert.Contains(t, backend.objects, "dataset/readers/id")
+	require.NoError(t, l.RUnlock(ctx))
+
+	// The same goes for an intent lost by the background refresher, which also lets the lock be taken in the other mode
+	require.NoError(t, l.Lock(ctx, time.Second))
+	_, err = ForceRelease(ctx, backend, "dataset/writer", "admin", "wedged")
+	require.NoError(t, err)
+	assert.ErrorIs(t, <-l.KeepAlive(ctx, time.Millisecond), ErrLockAbandoned)
+
+	require.NoError(t, l.RLock(ctx, time.Second))
+	assert.Contains(t, backend.objects, "dataset/readers/id")
+	require.NoError(t, l.RUnlock(ctx))
+}
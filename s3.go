@@ -0,0 +1,343 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"maps"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+const (
+	s3GenerationMetadata     = "lock-generation"
+	s3MetagenerationMetadata = "lock-metageneration"
+	// s3PendingMetadata marks a lock object which has been created but not yet given its generation
+	s3PendingMetadata = "lock-pending"
+	// s3GenerationCounter is the object at the root of the bucket holding the last generation given to a lock object
+	s3GenerationCounter = ".lock-generation"
+)
+
+var _ Backend = s3Backend{}
+
+type s3Backend struct {
+	client *s3.Client
+	bucket string
+}
+
+// NewS3Backend creates a Backend which stores lock objects in an Amazon S3 bucket, using conditional writes. S3 has no
+// equivalent of generations, so they are stored in the object's metadata, with the generation taken from a counter
+// object (.lock-generation at the root of the bucket) which is incremented with a conditional write on its ETag, so that
+// generations keep increasing however the clocks of the clients differ. An object is created before its generation is
+// taken, and until it's given one it's reported with a metageneration of zero and a generation derived from its ETag,
+// so that it's held as normal until it expires, such as if its creator dies part way through, and can then be
+// removed. Updates and deletes are made conditional on the ETag read alongside them. The last modified time reported
+// by S3 is used as the update time, which isn't known until the object is next read, and is rounded up to the next
+// second as S3 only reports it to the second.
+func NewS3Backend(client *s3.Client, bucket string) Backend {
+	return s3Backend{client: client, bucket: bucket}
+}
+
+func (s s3Backend) Create(ctx context.Context, path string, attrs ObjectAttrs) (*ObjectAttrs, error) { // nolint:gocritic
+	// The object is created before its generation is taken from the counter, so that anyone creating it after this has
+	// been removed is given a later generation, whatever order they read the counter in
+	pending, err := s.createPending(ctx, path, attrs)
+	if err != nil {
+		return nil, err
+	}
+
+	generation, err := s.nextGeneration(ctx)
+	if err != nil {
+		s.deletePending(ctx, path, pending)
+		return nil, fmt.Errorf("unable to generate a generation for %s: %w", path, err)
+	}
+
+	created := ObjectAttrs{
+		Metadata:       attrs.Metadata,
+		CacheControl:   attrs.CacheControl,
+		Generation:     generation,
+		Metageneration: 1,
+	}
+	_, err = s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:       aws.String(s.bucket),
+		Key:          aws.String(path),
+		CacheControl: optionalString(created.CacheControl),
+		IfMatch:      pending,
+		Metadata:     toS3Metadata(&created),
+	})
+	if err = s3Error(err); err != nil {
+		if !errors.Is(err, ErrPreconditionFailed) && !errors.Is(err, ErrNotExist) {
+			s.deletePending(ctx, path, pending)
+		}
+		return nil, err
+	}
+
+	return &created, nil
+}
+
+// createPending creates the object without a generation, returning its ETag.
+func (s s3Backend) createPending(ctx context.Context, path string, attrs ObjectAttrs) (*string, error) { // nolint:gocritic
+	metadata := maps.Clone(attrs.Metadata)
+	if metadata == nil {
+		metadata = map[string]string{}
+	}
+	metadata[s3PendingMetadata] = "true"
+
+	out, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:       aws.String(s.bucket),
+		Key:          aws.String(path),
+		CacheControl: optionalString(attrs.CacheControl),
+		IfNoneMatch:  aws.String("*"),
+		Metadata:     metadata,
+	})
+	if err != nil {
+		return nil, s3Error(err)
+	}
+
+	return out.ETag, nil
+}
+
+// deletePending removes the object if it still hasn't been given a generation, otherwise it's left to expire.
+func (s s3Backend) deletePending(ctx context.Context, path string, etag *string) {
+	_, _ = s.client.DeleteObject(context.WithoutCancel(ctx), &s3.DeleteObjectInput{
+		Bucket:  aws.String(s.bucket),
+		Key:     aws.String(path),
+		IfMatch: etag,
+	})
+}
+
+// nextGeneration increments the generation counter, returning the new value. The counter is compared and swapped on its
+// ETag, retrying whenever another client changes it first.
+func (s s3Backend) nextGeneration(ctx context.Context) (int64, error) {
+	for {
+		var last int64
+		input := &s3.PutObjectInput{
+			Bucket: aws.String(s.bucket),
+			Key:    aws.String(s3GenerationCounter),
+		}
+
+		out, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
+			Bucket: aws.String(s.bucket),
+			Key:    aws.String(s3GenerationCounter),
+		})
+		switch err := s3Error(err); {
+		case errors.Is(err, ErrNotExist):
+			input.IfNoneMatch = aws.String("*")
+		case err != nil:
+			return 0, err
+		default:
+			last, err = strconv.ParseInt(out.Metadata[s3GenerationMetadata], 10, 64)
+			if err != nil {
+				return 0, fmt.Errorf("invalid generation counter %s: %w", s3GenerationCounter, err)
+			}
+			input.IfMatch = out.ETag
+		}
+
+		input.Metadata = map[string]string{s3GenerationMetadata: strconv.FormatInt(last+1, 10)}
+		_, err = s.client.PutObject(ctx, input)
+		if err = s3Error(err); err == nil {
+			return last + 1, nil
+		}
+		if !errors.Is(err, ErrPreconditionFailed) {
+			return 0, err
+		}
+	}
+}
+
+func (s s3Backend) Update(ctx context.Context, path string, conditions Conditions, metadata map[string]string) (*ObjectAttrs, error) {
+	current, etag, err := s.head(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkConditions(current, conditions); err != nil {
+		return nil, err
+	}
+	if current.Metageneration == 0 {
+		// Updating the object would give it the generation derived from its ETag, rather than one from the counter
+		return nil, fmt.Errorf("%w: %s is still being created", ErrPreconditionFailed, path)
+	}
+
+	updated := ObjectAttrs{
+		Metadata:       metadata,
+		CacheControl:   current.CacheControl,
+		Generation:     current.Generation,
+		Metageneration: current.Metageneration + 1,
+	}
+
+	_, err = s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:       aws.String(s.bucket),
+		Key:          aws.String(path),
+		CacheControl: optionalString(updated.CacheControl),
+		IfMatch:      etag,
+		Metadata:     toS3Metadata(&updated),
+	})
+	if err != nil {
+		return nil, s3Error(err)
+	}
+
+	return &updated, nil
+}
+
+func (s s3Backend) Delete(ctx context.Context, path string, conditions Conditions) error {
+	current, etag, err := s.head(ctx, path)
+	if err != nil {
+		return err
+	}
+	if err := checkConditions(current, conditions); err != nil {
+		return err
+	}
+
+	_, err = s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket:  aws.String(s.bucket),
+		Key:     aws.String(path),
+		IfMatch: etag,
+	})
+	return s3Error(err)
+}
+
+func (s s3Backend) Attrs(ctx context.Context, path string) (*ObjectAttrs, error) {
+	attrs, _, err := s.head(ctx, path)
+	return attrs, err
+}
+
+func (s s3Backend) List(ctx context.Context, prefix string) ([]string, error) {
+	var paths []string
+	pages := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(prefix),
+	})
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(ctx)
+		if err != nil {
+			return nil, s3Error(err)
+		}
+		for _, object := range page.Contents {
+			paths = append(paths, aws.ToString(object.Key))
+		}
+	}
+
+	return paths, nil
+}
+
+func (s s3Backend) head(ctx context.Context, path string) (*ObjectAttrs, *string, error) {
+	out, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(path),
+	})
+	if err != nil {
+		return nil, nil, s3Error(err)
+	}
+
+	attrs, err := fromS3Metadata(out.Metadata, aws.ToString(out.ETag))
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid lock object %s: %w", path, err)
+	}
+	attrs.CacheControl = aws.ToString(out.CacheControl)
+	// The last modified time is truncated to the second, so round it up to avoid a lock appearing to expire early
+	attrs.Updated = aws.ToTime(out.LastModified).Add(time.Second)
+
+	return attrs, out.ETag, nil
+}
+
+// checkConditions applies conditions on the client side, for backends which can only compare and swap on something
+// other than the generations, such as an ETag.
+func checkConditions(attrs *ObjectAttrs, conditions Conditions) error {
+	if conditions.GenerationMatch != 0 && conditions.GenerationMatch != attrs.Generation {
+		return fmt.Errorf("%w: generation is %d not %d", ErrPreconditionFailed, attrs.Generation, conditions.GenerationMatch)
+	}
+	if conditions.MetagenerationMatch != 0 && conditions.MetagenerationMatch != attrs.Metageneration {
+		return fmt.Errorf("%w: metageneration is %d not %d", ErrPreconditionFailed, attrs.Metageneration, conditions.MetagenerationMatch)
+	}
+
+	return nil
+}
+
+func toS3Metadata(attrs *ObjectAttrs) map[string]string {
+	metadata := maps.Clone(attrs.Metadata)
+	if metadata == nil {
+		metadata = map[string]string{}
+	}
+	metadata[s3GenerationMetadata] = strconv.FormatInt(attrs.Generation, 10)
+	metadata[s3MetagenerationMetadata] = strconv.FormatInt(attrs.Metageneration, 10)
+
+	return metadata
+}
+
+func fromS3Metadata(metadata map[string]string, etag string) (*ObjectAttrs, error) {
+	if _, ok := metadata[s3PendingMetadata]; ok {
+		metadata = maps.Clone(metadata)
+		delete(metadata, s3PendingMetadata)
+
+		return &ObjectAttrs{
+			Metadata:   metadata,
+			Generation: pendingGeneration(etag),
+		}, nil
+	}
+
+	generation, err := strconv.ParseInt(metadata[s3GenerationMetadata], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("generation: %w", err)
+	}
+	metageneration, err := strconv.ParseInt(metadata[s3MetagenerationMetadata], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("metageneration: %w", err)
+	}
+
+	metadata = maps.Clone(metadata)
+	delete(metadata, s3GenerationMetadata)
+	delete(metadata, s3MetagenerationMetadata)
+
+	return &ObjectAttrs{
+		Metadata:       metadata,
+		Generation:     generation,
+		Metageneration: metageneration,
+	}, nil
+}
+
+// pendingGeneration derives a generation from the ETag of an object which hasn't been given one yet, so that it can be
+// removed under the same conditions as any other object. It's never zero, but is otherwise meaningless.
+func pendingGeneration(etag string) int64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(etag))
+	if generation := int64(h.Sum64() >> 1); generation != 0 { // nolint:gosec
+		return generation
+	}
+	return 1
+}
+
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+
+	return aws.String(s)
+}
+
+// s3Error maps errors from Amazon S3 on to the errors expected from a Backend, keeping the original error so the
+// details aren't lost.
+func s3Error(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var respErr *awshttp.ResponseError
+	if !errors.As(err, &respErr) {
+		return err
+	}
+
+	switch respErr.HTTPStatusCode() {
+	case http.StatusNotFound:
+		return fmt.Errorf("%w: %w", ErrNotExist, err)
+	case http.StatusPreconditionFailed, http.StatusConflict:
+		// A conflict is returned when a conditional write races with another one
+		return fmt.Errorf("%w: %w", ErrPreconditionFailed, err)
+	default:
+		return err
+	}
+}
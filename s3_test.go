@@ -0,0 +1,216 @@
+package lock
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thg-ice/distributed-lock/mock_s3"
+)
+
+func TestS3Backend(t *testing.T) {
+	mock := mock_s3.NewServer("b")
+	t.Cleanup(mock.Close)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	t.Cleanup(cancel)
+
+	client, err := mock.Client(ctx)
+	require.NoError(t, err)
+
+	subject := NewS3Backend(client, "b")
+
+	_, err = subject.Attrs(ctx, "testing")
+	assert.ErrorIs(t, err, ErrNotExist)
+
+	created, err := subject.Create(ctx, "testing", ObjectAttrs{CacheControl: "no-store", Metadata: map[string]string{"k": "v"}})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"k": "v"}, created.Metadata)
+	assert.Equal(t, "no-store", created.CacheControl)
+	assert.NotZero(t, created.Generation)
+	assert.Equal(t, int64(1), created.Metageneration)
+
+	read, err := subject.Attrs(ctx, "testing")
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), read.Updated, time.Minute)
+	read.Updated = time.Time{}
+	assert.Equal(t, created, read)
+
+	_, err = subject.Create(ctx, "testing", ObjectAttrs{})
+	assert.ErrorIs(t, err, ErrPreconditionFailed)
+
+	_, err = subject.Update(ctx, "testing", Conditions{GenerationMatch: created.Generation + 1}, map[string]string{"k": "other"})
+	assert.ErrorIs(t, err, ErrPreconditionFailed)
+	_, err = subject.Update(ctx, "testing", Conditions{MetagenerationMatch: 2}, map[string]string{"k": "other"})
+	assert.ErrorIs(t, err, ErrPreconditionFailed)
+
+	updated, err := subject.Update(ctx, "testing",
+		Conditions{GenerationMatch: created.Generation, MetagenerationMatch: 1}, map[string]string{"k": "other"})
+	require.NoError(t, err)
+	assert.Equal(t, created.Generation, updated.Generation)
+	assert.Equal(t, int64(2), updated.Metageneration)
+	assert.Equal(t, map[string]string{"k": "other"}, updated.Metadata)
+	assert.Equal(t, "no-store", updated.CacheControl)
+
+	read, err = subject.Attrs(ctx, "testing")
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), read.Updated, time.Minute)
+	read.Updated = time.Time{}
+	assert.Equal(t, updated, read)
+
+	assert.ErrorIs(t, subject.Delete(ctx, "testing", Conditions{MetagenerationMatch: 1}), ErrPreconditionFailed)
+	require.NoError(t, subject.Delete(ctx, "testing", Conditions{MetagenerationMatch: 2}))
+	assert.ErrorIs(t, subject.Delete(ctx, "testing", Conditions{MetagenerationMatch: 2}), ErrNotExist)
+	assert.Nil(t, mock.Get("testing"))
+
+	for _, path := range []string{"list/b", "list/a/1", "listing", "other"} {
+		_, err = subject.Create(ctx, path, ObjectAttrs{})
+		require.NoError(t, err)
+	}
+	paths, err := subject.List(ctx, "list")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"list/a/1", "list/b", "listing"}, paths)
+	paths, err = subject.List(ctx, "list/")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"list/a/1", "list/b"}, paths)
+	paths, err = subject.List(ctx, "missing/")
+	require.NoError(t, err)
+	assert.Empty(t, paths)
+}
+
+func TestLock_WithS3Backend(t *testing.T) {
+	mock := mock_s3.NewServer("b")
+	t.Cleanup(mock.Close)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	t.Cleanup(cancel)
+
+	client, err := mock.Client(ctx)
+	require.NoError(t, err)
+
+	logger := func(context.Context) Logger {
+		return loggerToTestingT{t}
+	}
+	first := NewLockWithBackend(NewS3Backend(client, "b"), "first", "testing", time.Minute, logger)
+	second := NewLockWithBackend(NewS3Backend(client, "b"), "second", "testing", time.Minute, logger)
+
+	require.NoError(t, first.Lock(ctx, 100*time.Millisecond))
+	assert.Equal(t, "first", mock.Get("testing").Metadata[ownerMetadata])
+	assert.Equal(t, "no-store", mock.Get("testing").CacheControl)
+
+	assert.ErrorIs(t, second.Lock(ctx, 100*time.Millisecond), context.DeadlineExceeded)
+
+	require.NoError(t, first.RefreshLock(ctx))
+	assert.Equal(t, "2", mock.Get("testing").Metadata["lock-metageneration"])
+
+	require.NoError(t, first.Unlock(ctx))
+	assert.Nil(t, mock.Get("testing"))
+
+	require.NoError(t, second.Lock(ctx, 100*time.Millisecond))
+	assert.Greater(t, second.FencingToken(), first.latestGeneration)
+	assert.Equal(t, "second", mock.Get("testing").Metadata[ownerMetadata])
+}
+
+func TestS3Backend_Generations(t *testing.T) {
+	mock := mock_s3.NewServer("b")
+	t.Cleanup(mock.Close)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	t.Cleanup(cancel)
+
+	client, err := mock.Client(ctx)
+	require.NoError(t, err)
+
+	// Generations carry on from the counter in the bucket, rather than depending on the clock of the client
+	mock.Add(s3GenerationCounter, mock_s3.Object{Metadata: map[string]string{s3GenerationMetadata: "41"}})
+
+	before := time.Now().Truncate(time.Second)
+	first, err := NewS3Backend(client, "b").Create(ctx, "first", ObjectAttrs{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(42), first.Generation)
+	second, err := NewS3Backend(client, "b").Create(ctx, "second", ObjectAttrs{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(43), second.Generation)
+	assert.Equal(t, "43", mock.Get(s3GenerationCounter).Metadata[s3GenerationMetadata])
+
+	read, err := NewS3Backend(client, "b").Attrs(ctx, "first")
+	require.NoError(t, err)
+	assert.Equal(t, int64(42), read.Generation)
+	// The update time is only known to the second, so it's rounded up rather than appearing earlier than it was
+	assert.True(t, read.Updated.After(before), "updated %s before %s", read.Updated, before)
+
+	// The counter is created if it doesn't exist, and can be raced by other clients
+	mock.RemoveAll()
+	var wg sync.WaitGroup
+	generations := make([]int64, 5)
+	for i := range generations {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			created, err := NewS3Backend(client, "b").Create(ctx, fmt.Sprintf("concurrent/%d", i), ObjectAttrs{})
+			if assert.NoError(t, err) {
+				generations[i] = created.Generation
+			}
+		}()
+	}
+	wg.Wait()
+	assert.ElementsMatch(t, []int64{1, 2, 3, 4, 5}, generations)
+}
+
+func TestLock_WithS3Backend_PendingLock(t *testing.T) {
+	mock := mock_s3.NewServer("b")
+	t.Cleanup(mock.Close)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	t.Cleanup(cancel)
+
+	client, err := mock.Client(ctx)
+	require.NoError(t, err)
+
+	logger := func(context.Context) Logger {
+		return loggerToTestingT{t}
+	}
+	backend := NewS3Backend(client, "b").(s3Backend)
+	first := NewLockWithBackend(backend, "first", "testing", 100*time.Millisecond, logger)
+	second := NewLockWithBackend(backend, "second", "testing", time.Minute, logger)
+
+	// The creator dies between creating the lock and giving it a generation
+	_, err = backend.createPending(ctx, "testing", ObjectAttrs{Metadata: first.metadata()})
+	require.NoError(t, err)
+
+	attrs, err := backend.Attrs(ctx, "testing")
+	require.NoError(t, err)
+	assert.NotZero(t, attrs.Generation)
+	assert.Zero(t, attrs.Metageneration)
+	assert.Equal(t, "first", attrs.Metadata[ownerMetadata])
+	assert.NotContains(t, attrs.Metadata, s3PendingMetadata)
+
+	_, err = backend.Update(ctx, "testing", Conditions{GenerationMatch: attrs.Generation}, first.metadata())
+	assert.ErrorIs(t, err, ErrPreconditionFailed)
+
+	// It's held like any other lock until it expires
+	acquired, holder, err := second.TryLock(ctx)
+	require.NoError(t, err)
+	assert.False(t, acquired)
+	assert.Equal(t, "first", holder.Owner)
+
+	require.NoError(t, second.Lock(ctx, 5*time.Second))
+	assert.Equal(t, "second", mock.Get("testing").Metadata[ownerMetadata])
+	assert.NotContains(t, mock.Get("testing").Metadata, s3PendingMetadata)
+	require.NoError(t, second.Unlock(ctx))
+
+	// The identity which left it behind removes it straight away
+	_, err = backend.createPending(ctx, "testing", ObjectAttrs{Metadata: first.metadata()})
+	require.NoError(t, err)
+
+	acquired, _, err = first.TryLock(ctx)
+	require.NoError(t, err)
+	assert.True(t, acquired)
+	assert.NotZero(t, first.FencingToken())
+	assert.Equal(t, "1", mock.Get("testing").Metadata["lock-metageneration"])
+}
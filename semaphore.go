@@ -0,0 +1,171 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Semaphore allows up to a fixed number of holders at once, rather than the mutual exclusion of a Lock. Each holder
+// takes one of the slots, which are lock objects under a common prefix, and is otherwise treated in the same way as the
+// holder of a Lock with the same options, including TTL expiry and the removal of stale slots. As with a Lock, the
+// identity must be unique to each holder.
+type Semaphore struct {
+	slots   []*Lock
+	backoff Backoff
+
+	mutex sync.Mutex
+	held  *Lock
+}
+
+// NewSemaphore creates a semaphore allowing up to size holders, using slot objects under the prefix in the Backend. The
+// options are applied to the Lock for each slot.
+func NewSemaphore(backend Backend, identity, prefix string, size int, opts ...Option) (*Semaphore, error) {
+	if size <= 0 {
+		return nil, errors.New("invalid semaphore configuration: size must be positive")
+	}
+	if prefix == "" {
+		return nil, errors.New("invalid semaphore configuration: prefix must not be empty")
+	}
+
+	s := &Semaphore{slots: make([]*Lock, size)}
+	for i := range s.slots {
+		slot, err := New(backend, identity, semaphoreSlotPath(prefix, i), opts...)
+		if err != nil {
+			return nil, err
+		}
+		s.slots[i] = slot
+	}
+	s.backoff = s.slots[0].backoff
+
+	return s, nil
+}
+
+func semaphoreSlotPath(prefix string, slot int) string {
+	return fmt.Sprintf("%s/slot-%d", strings.TrimSuffix(prefix, "/"), slot)
+}
+
+// Acquire will attempt to take a slot until the context has timed out, trying every slot in turn before waiting as
+// decided by the configured Backoff. If no slot is free in time, a TimeoutError is returned in the same way as Lock.
+func (s *Semaphore) Acquire(ctx context.Context, timeout time.Duration) error {
+	return retry(ctx, s.slots[0].clock, timeout, s.backoff, func(ctx context.Context) error {
+		_, err := s.TryAcquire(ctx)
+		return err
+	})
+}
+
+// TryAcquire makes a single attempt to take each slot without waiting, returning whether one was taken. If every slot
+// is held, a LockHeldError for one of them is returned. Nothing is done if a slot is already held.
+func (s *Semaphore) TryAcquire(ctx context.Context) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.held != nil {
+		return true, nil
+	}
+
+	// Start from a random slot, so that holders don't all contend for the first one
+	offset := rand.IntN(len(s.slots)) // nolint:gosec
+	var errs []error
+	for i := range s.slots {
+		slot := s.slots[(offset+i)%len(s.slots)]
+		acquired, holder, err := slot.TryLock(ctx)
+		if acquired {
+			s.held = slot
+			return true, nil
+		}
+		if holder != nil {
+			err = &LockHeldError{Holder: *holder}
+		}
+		errs = append(errs, err)
+	}
+
+	// Report a single holder when every slot is taken, rather than all of them
+	for _, err := range errs {
+		if !errors.Is(err, ErrLockHeld) {
+			return false, err
+		}
+	}
+	return false, errs[len(errs)-1]
+}
+
+// Refresh will update the slot held, in the same way as Lock.RefreshLock. Once the slot has been lost, it's no longer
+// treated as held, so that the next Acquire or TryAcquire takes a slot again.
+func (s *Semaphore) Refresh(ctx context.Context) error {
+	held := s.heldSlot()
+	if held == nil {
+		return nil
+	}
+
+	err := held.RefreshLock(ctx)
+	if errors.Is(err, ErrLockAbandoned) {
+		s.lost(held)
+	}
+	return err
+}
+
+// KeepAlive starts a background refresher for the slot held, in the same way as Lock.KeepAlive. If no slot is held,
+// ErrLockAbandoned is sent on the returned channel straight away. As with Refresh, a lost slot is no longer held.
+func (s *Semaphore) KeepAlive(ctx context.Context, interval time.Duration) <-chan error {
+	held := s.heldSlot()
+	if held == nil {
+		lost := make(chan error, 1)
+		lost <- ErrLockAbandoned
+		close(lost)
+		return lost
+	}
+
+	return held.keepAlive(ctx, interval, nil, func() {
+		s.lost(held)
+	})
+}
+
+// Release gives up the slot held, stopping any refresher started by KeepAlive first.
+func (s *Semaphore) Release(ctx context.Context) error {
+	// The refresher takes the mutex if the slot is lost, so it's stopped before the mutex is held
+	if held := s.heldSlot(); held != nil {
+		held.stopRefreshing()
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.held == nil {
+		return nil
+	}
+
+	err := s.held.Unlock(ctx)
+	s.held = nil
+	return err
+}
+
+// Slot returns the path of the slot held, or an empty string if none is held.
+func (s *Semaphore) Slot() string {
+	held := s.heldSlot()
+	if held == nil {
+		return ""
+	}
+
+	return held.path
+}
+
+func (s *Semaphore) heldSlot() *Lock {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.held
+}
+
+// lost stops treating the slot as held, unless another has been taken since.
+func (s *Semaphore) lost(slot *Lock) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.held == slot {
+		s.held = nil
+	}
+}
@@ -0,0 +1,149 @@
+package lock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewSemaphore(t *testing.T) {
+	tests := []struct {
+		name        string
+		prefix      string
+		size        int
+		opts        []Option
+		expectedErr string
+	}{
+		{
+			name:   "valid",
+			prefix: "semaphore",
+			size:   3,
+		},
+		{
+			name:        "invalid-size",
+			prefix:      "semaphore",
+			size:        0,
+			expectedErr: "size must be positive",
+		},
+		{
+			name:        "missing-prefix",
+			size:        3,
+			expectedErr: "prefix must not be empty",
+		},
+		{
+			name:        "invalid-options",
+			prefix:      "semaphore",
+			size:        3,
+			opts:        []Option{WithTTL(0)},
+			expectedErr: "TTL must be positive",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s, err := NewSemaphore(newMemoryBackend(), "id", test.prefix, test.size, test.opts...)
+			if test.expectedErr == "" {
+				require.NoError(t, err)
+				assert.Len(t, s.slots, test.size)
+			} else {
+				assert.ErrorContains(t, err, test.expectedErr)
+			}
+		})
+	}
+}
+
+func TestSemaphore(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	t.Cleanup(cancel)
+
+	backend := newMemoryBackend()
+	newSemaphore := func(identity string) *Semaphore {
+		s, err := NewSemaphore(backend, identity, "semaphore/", 2, WithTTL(time.Minute), WithRetryInterval(10*time.Millisecond),
+			WithLogger(func(context.Context) Logger {
+				return loggerToTestingT{t}
+			}))
+		require.NoError(t, err)
+		return s
+	}
+	first, second, third := newSemaphore("first"), newSemaphore("second"), newSemaphore("third")
+
+	require.NoError(t, first.Acquire(ctx, time.Second))
+	require.NoError(t, second.Acquire(ctx, time.Second))
+	assert.ElementsMatch(t, []string{"semaphore/slot-0", "semaphore/slot-1"}, []string{first.Slot(), second.Slot()})
+
+	// Acquiring again keeps the same slot
+	slot := first.Slot()
+	require.NoError(t, first.Acquire(ctx, time.Second))
+	assert.Equal(t, slot, first.Slot())
+
+	acquired, err := third.TryAcquire(ctx)
+	assert.False(t, acquired)
+	assert.ErrorIs(t, err, ErrLockHeld)
+	assert.ErrorIs(t, third.Acquire(ctx, 100*time.Millisecond), ErrTimeout)
+	assert.Empty(t, third.Slot())
+
+	require.NoError(t, first.Refresh(ctx))
+	require.NoError(t, first.Release(ctx))
+	assert.Empty(t, first.Slot())
+
+	require.NoError(t, third.Acquire(ctx, time.Second))
+	assert.Equal(t, slot, third.Slot())
+	assert.Equal(t, "third", backend.objects[slot].Metadata[ownerMetadata])
+
+	require.NoError(t, second.Release(ctx))
+	require.NoError(t, third.Release(ctx))
+	assert.Empty(t, backend.objects)
+}
+
+func TestSemaphore_KeepAlive(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	t.Cleanup(cancel)
+
+	s, err := NewSemaphore(newMemoryBackend(), "id", "semaphore", 1, WithTTL(time.Minute))
+	require.NoError(t, err)
+
+	assert.ErrorIs(t, <-s.KeepAlive(ctx, time.Millisecond), ErrLockAbandoned)
+
+	require.NoError(t, s.Acquire(ctx, time.Second))
+	lost := s.KeepAlive(ctx, time.Millisecond)
+	time.Sleep(10 * time.Millisecond)
+	require.NoError(t, s.Release(ctx))
+
+	_, ok := <-lost
+	assert.False(t, ok)
+}
+
+func TestSemaphore_Lost(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	t.Cleanup(cancel)
+
+	backend := newMemoryBackend()
+	s, err := NewSemaphore(backend, "id", "semaphore", 1, WithTTL(time.Minute))
+	require.NoError(t, err)
+
+	// A slot lost when refreshing is taken again by the next attempt, rather than it being treated as still held
+	require.NoError(t, s.Acquire(ctx, time.Second))
+	_, err = ForceRelease(ctx, backend, s.Slot(), "admin", "wedged")
+	require.NoError(t, err)
+	assert.ErrorIs(t, s.Refresh(ctx), ErrLockAbandoned)
+	assert.Empty(t, s.Slot())
+
+	acquired, err := s.TryAcquire(ctx)
+	require.NoError(t, err)
+	assert.True(t, acquired)
+	require.Contains(t, backend.objects, s.Slot())
+	assert.Equal(t, "id", backend.objects[s.Slot()].Metadata[ownerMetadata])
+
+	// The same goes for a slot lost by the background refresher
+	_, err = ForceRelease(ctx, backend, s.Slot(), "admin", "wedged")
+	require.NoError(t, err)
+	assert.ErrorIs(t, <-s.KeepAlive(ctx, time.Millisecond), ErrLockAbandoned)
+	assert.Empty(t, s.Slot())
+
+	require.NoError(t, s.Acquire(ctx, time.Second))
+	assert.Contains(t, backend.objects, s.Slot())
+	require.NoError(t, s.Release(ctx))
+}